@@ -0,0 +1,298 @@
+package kapacitor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/influxdata/chronograf"
+)
+
+// AlertmanagerRule is the alert-generation rule Alertmanager runs on behalf
+// of a chronograf.AlertRule. Prometheus Alertmanager has no notion of
+// evaluating queries itself; Chronograf owns translating the rule into this
+// shape and registering it, while the actual alert firing is handled by
+// whatever is scraping metrics and pushing alerts into Alertmanager.
+//
+// Labels carries a "state" entry of "enabled" or "disabled" alongside the
+// alertmanager-native labels: Alertmanager has no pause/resume concept of
+// its own, so Chronograf tracks it the same way it tracks everything else
+// about the rule, by round-tripping it through Update.
+type AlertmanagerRule struct {
+	Alert       string            `json:"alert"`
+	Labels      map[string]string `json:"labels"`
+	Annotations map[string]string `json:"annotations"`
+}
+
+// AlertmanagerBackend dispatches AlertRules to a Prometheus Alertmanager
+// instance by translating them into an alert-generation config and routing
+// tree entry, rather than a Kapacitor TICKscript task.
+type AlertmanagerBackend struct {
+	// URL is the base address of the Alertmanager API, e.g. http://localhost:9093
+	URL string
+	// Client performs the HTTP requests; defaults to http.DefaultClient.
+	Client *http.Client
+}
+
+// NewAlertmanagerBackend creates an AlertBackend that targets the
+// Alertmanager instance at url.
+func NewAlertmanagerBackend(url string) *AlertmanagerBackend {
+	return &AlertmanagerBackend{
+		URL:    url,
+		Client: http.DefaultClient,
+	}
+}
+
+// toAlertmanagerRule translates a chronograf.AlertRule into the alert and
+// routing config Alertmanager needs to manage it. New rules always start
+// enabled; toggling that afterwards is Enable/Disable's job.
+func toAlertmanagerRule(rule chronograf.AlertRule) AlertmanagerRule {
+	labels := map[string]string{
+		"alertname": rule.Name,
+		"rule_id":   rule.ID,
+		"state":     "enabled",
+	}
+	if rule.Query != nil {
+		labels["database"] = rule.Query.Database
+		labels["retention_policy"] = rule.Query.RetentionPolicy
+	}
+	return AlertmanagerRule{
+		Alert:  rule.Name,
+		Labels: labels,
+		Annotations: map[string]string{
+			"rule_id": rule.ID,
+		},
+	}
+}
+
+// amRevision hashes the full posted body, not just the alert name, so a
+// concurrent edit to any field Update can change (including labels derived
+// from rule.Query) is reflected in the revision Update's CAS check compares
+// against.
+func amRevision(amRule AlertmanagerRule) string {
+	b, err := json.Marshal(amRule)
+	if err != nil {
+		return hashRevision(amRule.Alert)
+	}
+	return hashRevision(string(b))
+}
+
+func (a *AlertmanagerBackend) do(ctx context.Context, method, path string, body interface{}) (*http.Response, error) {
+	var buf bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&buf).Encode(body); err != nil {
+			return nil, err
+		}
+	}
+	req, err := http.NewRequest(method, a.URL+path, &buf)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	return a.Client.Do(req)
+}
+
+// href is the single resource path used for both writing and reading an
+// alert-generation config, so a Task handed back by Create is the same
+// href every later Get/Update/Delete/Enable/Disable/Status call can use.
+func (a *AlertmanagerBackend) href(id string) string {
+	return "/api/v2/alerts/groups/" + id
+}
+
+// fetchRules GETs href and decodes the AlertmanagerRule group registered
+// there, returning chronograf.ErrAlertNotFound if nothing is registered.
+func (a *AlertmanagerBackend) fetchRules(ctx context.Context, href string) ([]AlertmanagerRule, error) {
+	resp, err := a.do(ctx, "GET", href, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return nil, chronograf.ErrAlertNotFound
+	}
+
+	var rules []AlertmanagerRule
+	if err := json.NewDecoder(resp.Body).Decode(&rules); err != nil || len(rules) == 0 {
+		return nil, chronograf.ErrAlertNotFound
+	}
+	return rules, nil
+}
+
+// Create registers rule's alert-generation config with Alertmanager.
+func (a *AlertmanagerBackend) Create(ctx context.Context, rule chronograf.AlertRule) (*Task, error) {
+	kapaID := Prefix + rule.ID
+	href := a.href(kapaID)
+	amRule := toAlertmanagerRule(rule)
+
+	resp, err := a.do(ctx, "POST", href, []AlertmanagerRule{amRule})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		b, _ := ioutil.ReadAll(resp.Body)
+		return nil, fmt.Errorf("alertmanager: create failed: %s: %s", resp.Status, string(b))
+	}
+
+	rule.ID = kapaID
+	return &Task{
+		ID:       kapaID,
+		Href:     href,
+		Rule:     rule,
+		Revision: amRevision(amRule),
+	}, nil
+}
+
+// Update replaces the alert-generation config at href. Alertmanager has no
+// revision of its own, so when revision is non-empty it's checked against a
+// hash of the currently registered rule. The rule's current enabled/disabled
+// state is preserved across the replace, since Update shouldn't silently
+// re-enable something Disable turned off.
+func (a *AlertmanagerBackend) Update(ctx context.Context, href, revision string, rule chronograf.AlertRule) (*Task, error) {
+	current, err := a.fetchRules(ctx, href)
+	if err != nil {
+		return nil, err
+	}
+	if revision != "" && amRevision(current[0]) != revision {
+		return nil, ErrConflict{ID: rule.ID}
+	}
+
+	amRule := toAlertmanagerRule(rule)
+	if state, ok := current[0].Labels["state"]; ok {
+		amRule.Labels["state"] = state
+	}
+
+	resp, err := a.do(ctx, "PUT", href, []AlertmanagerRule{amRule})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		b, _ := ioutil.ReadAll(resp.Body)
+		return nil, fmt.Errorf("alertmanager: update failed: %s: %s", resp.Status, string(b))
+	}
+	return &Task{
+		ID:       rule.ID,
+		Href:     href,
+		Rule:     rule,
+		Revision: amRevision(amRule),
+	}, nil
+}
+
+// Delete removes the alert-generation config at href.
+func (a *AlertmanagerBackend) Delete(ctx context.Context, href string) error {
+	resp, err := a.do(ctx, "DELETE", href, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		b, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("alertmanager: delete failed: %s: %s", resp.Status, string(b))
+	}
+	return nil
+}
+
+// setState PUTs href's rule back with its "state" label set to state,
+// without removing the rule itself, so Disable followed by Enable recovers
+// the same rule instead of losing it.
+func (a *AlertmanagerBackend) setState(ctx context.Context, href, state string) (*Task, error) {
+	current, err := a.fetchRules(ctx, href)
+	if err != nil {
+		return nil, err
+	}
+
+	amRule := current[0]
+	if amRule.Labels == nil {
+		amRule.Labels = map[string]string{}
+	}
+	amRule.Labels["state"] = state
+
+	resp, err := a.do(ctx, "PUT", href, []AlertmanagerRule{amRule})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		b, _ := ioutil.ReadAll(resp.Body)
+		return nil, fmt.Errorf("alertmanager: set state failed: %s: %s", resp.Status, string(b))
+	}
+	return &Task{Href: href, Revision: amRevision(amRule)}, nil
+}
+
+// Enable marks the rule at href enabled.
+func (a *AlertmanagerBackend) Enable(ctx context.Context, href string) (*Task, error) {
+	return a.setState(ctx, href, "enabled")
+}
+
+// Disable marks the rule at href disabled without deleting its
+// alert-generation config, so a later Enable can recover it.
+func (a *AlertmanagerBackend) Disable(ctx context.Context, href string) (*Task, error) {
+	return a.setState(ctx, href, "disabled")
+}
+
+// Get returns the AlertRule and revision behind a single Alertmanager rule
+// group.
+func (a *AlertmanagerBackend) Get(ctx context.Context, id string) (chronograf.AlertRule, string, error) {
+	rules, err := a.fetchRules(ctx, a.href(id))
+	if err != nil {
+		return chronograf.AlertRule{}, "", err
+	}
+	rule := chronograf.AlertRule{
+		ID:   id,
+		Name: rules[0].Alert,
+	}
+	return rule, amRevision(rules[0]), nil
+}
+
+// All returns every alert-generation config registered with Alertmanager,
+// along with each one's revision.
+func (a *AlertmanagerBackend) All(ctx context.Context) (map[string]chronograf.AlertRule, map[string]string, error) {
+	resp, err := a.do(ctx, "GET", "/api/v2/alerts/groups", nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		b, _ := ioutil.ReadAll(resp.Body)
+		return nil, nil, fmt.Errorf("alertmanager: list failed: %s: %s", resp.Status, string(b))
+	}
+
+	var groups map[string][]AlertmanagerRule
+	if err := json.NewDecoder(resp.Body).Decode(&groups); err != nil {
+		return nil, nil, err
+	}
+
+	alerts := map[string]chronograf.AlertRule{}
+	revisions := map[string]string{}
+	for id, rules := range groups {
+		if len(rules) == 0 {
+			continue
+		}
+		alerts[id] = chronograf.AlertRule{
+			ID:   id,
+			Name: rules[0].Alert,
+		}
+		revisions[id] = amRevision(rules[0])
+	}
+	return alerts, revisions, nil
+}
+
+// Status reports the rule at href's "state" label, defaulting to "enabled"
+// for rules created before Chronograf tracked it.
+func (a *AlertmanagerBackend) Status(ctx context.Context, href string) (string, error) {
+	rules, err := a.fetchRules(ctx, href)
+	if err != nil {
+		return "", err
+	}
+	if state, ok := rules[0].Labels["state"]; ok && state != "" {
+		return state, nil
+	}
+	return "enabled", nil
+}
+
+var _ AlertBackend = &AlertmanagerBackend{}
@@ -0,0 +1,176 @@
+package kapacitor
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/influxdata/chronograf"
+)
+
+// fakeAlertmanager is a minimal in-memory stand-in for the Alertmanager
+// group endpoints AlertmanagerBackend talks to: POST/PUT/GET/DELETE on a
+// single group's href, and GET across every group for All.
+type fakeAlertmanager struct {
+	mu     sync.Mutex
+	groups map[string][]AlertmanagerRule
+}
+
+func newFakeAlertmanager() *httptest.Server {
+	fa := &fakeAlertmanager{groups: map[string][]AlertmanagerRule{}}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fa.mu.Lock()
+		defer fa.mu.Unlock()
+
+		if r.URL.Path == "/api/v2/alerts/groups" && r.Method == "GET" {
+			json.NewEncoder(w).Encode(fa.groups)
+			return
+		}
+
+		switch r.Method {
+		case "POST", "PUT":
+			var rules []AlertmanagerRule
+			if err := json.NewDecoder(r.Body).Decode(&rules); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			fa.groups[r.URL.Path] = rules
+			w.WriteHeader(http.StatusOK)
+		case "GET":
+			rules, ok := fa.groups[r.URL.Path]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			json.NewEncoder(w).Encode(rules)
+		case "DELETE":
+			if _, ok := fa.groups[r.URL.Path]; !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			delete(fa.groups, r.URL.Path)
+			w.WriteHeader(http.StatusOK)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	}))
+}
+
+func TestAlertmanagerBackendCreateGetUpdateDeleteRoundTrip(t *testing.T) {
+	srv := newFakeAlertmanager()
+	defer srv.Close()
+	a := NewAlertmanagerBackend(srv.URL)
+
+	rule := chronograf.AlertRule{ID: "cpu-high", Name: "cpu high"}
+	created, err := a.Create(context.Background(), rule)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// The href Create returns must be the same resource Create wrote to, so
+	// Get/Update/Delete against it actually find something.
+	got, revision, err := a.Get(context.Background(), created.ID)
+	if err != nil {
+		t.Fatalf("Get against Create's href failed: %v", err)
+	}
+	if got.Name != rule.Name {
+		t.Fatalf("expected Name %q, got %q", rule.Name, got.Name)
+	}
+	if revision != created.Revision {
+		t.Fatalf("expected Get's revision %q to match Create's %q", revision, created.Revision)
+	}
+
+	updated, err := a.Update(context.Background(), created.Href, created.Revision, chronograf.AlertRule{ID: created.ID, Name: "cpu very high"})
+	if err != nil {
+		t.Fatalf("Update against Create's href failed: %v", err)
+	}
+
+	got, _, err = a.Get(context.Background(), created.ID)
+	if err != nil {
+		t.Fatalf("Get after Update failed: %v", err)
+	}
+	if got.Name != "cpu very high" {
+		t.Fatalf("expected Update to take effect, got Name %q", got.Name)
+	}
+
+	if err := a.Delete(context.Background(), updated.Href); err != nil {
+		t.Fatalf("Delete against Create's href failed: %v", err)
+	}
+	if _, _, err := a.Get(context.Background(), created.ID); err != chronograf.ErrAlertNotFound {
+		t.Fatalf("expected ErrAlertNotFound after Delete, got %v", err)
+	}
+}
+
+// TestAlertmanagerBackendDisableThenEnableRecoversRule pins the fix for a
+// bug where Disable hard-deleted the Alertmanager resource, so a following
+// Enable (a GET) always reported the rule as gone rather than turning it
+// back on.
+func TestAlertmanagerBackendDisableThenEnableRecoversRule(t *testing.T) {
+	srv := newFakeAlertmanager()
+	defer srv.Close()
+	a := NewAlertmanagerBackend(srv.URL)
+
+	created, err := a.Create(context.Background(), chronograf.AlertRule{ID: "cpu-high", Name: "cpu high"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, err := a.Disable(context.Background(), created.Href); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if status, err := a.Status(context.Background(), created.Href); err != nil || status != "disabled" {
+		t.Fatalf("expected status disabled, got %q, %v", status, err)
+	}
+
+	if _, err := a.Enable(context.Background(), created.Href); err != nil {
+		t.Fatalf("expected Enable to recover the rule Disable turned off, got %v", err)
+	}
+	if status, err := a.Status(context.Background(), created.Href); err != nil || status != "enabled" {
+		t.Fatalf("expected status enabled after Enable, got %q, %v", status, err)
+	}
+
+	// And the rule itself must still be there, not just its status.
+	if _, _, err := a.Get(context.Background(), created.ID); err != nil {
+		t.Fatalf("expected the rule to still exist after disable/enable, got %v", err)
+	}
+}
+
+// TestAlertmanagerBackendUpdateDetectsConflict pins the fix for a CAS gap
+// where the revision only hashed the alert name, so a concurrent edit to
+// rule.Query (which changes the database/retention_policy labels) wasn't
+// caught by Update's conflict check.
+func TestAlertmanagerBackendUpdateDetectsConflict(t *testing.T) {
+	srv := newFakeAlertmanager()
+	defer srv.Close()
+	a := NewAlertmanagerBackend(srv.URL)
+
+	rule := chronograf.AlertRule{
+		ID:   "cpu-high",
+		Name: "cpu high",
+		Query: &chronograf.QueryConfig{
+			Database:        "telegraf",
+			RetentionPolicy: "autogen",
+		},
+	}
+	created, err := a.Create(context.Background(), rule)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Someone else changes the query's retention policy without renaming
+	// the rule.
+	concurrent := rule
+	concurrent.Query = &chronograf.QueryConfig{Database: "telegraf", RetentionPolicy: "two_weeks"}
+	if _, err := a.Update(context.Background(), created.Href, "", concurrent); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, err := a.Update(context.Background(), created.Href, created.Revision, rule); err == nil {
+		t.Fatalf("expected Update to report a conflict for a Query-only change, got nil")
+	} else if _, ok := err.(ErrConflict); !ok {
+		t.Fatalf("expected ErrConflict, got %v", err)
+	}
+}
@@ -0,0 +1,218 @@
+package kapacitor
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+
+	"github.com/influxdata/chronograf"
+)
+
+// AlertBackend is implemented by anything capable of running
+// chronograf.AlertRules as scheduled tasks. Client implements this
+// interface for Kapacitor; other implementations let users who don't run
+// Kapacitor still author and manage alerts from Chronograf.
+type AlertBackend interface {
+	// Create builds and installs a task for rule, returning the running Task.
+	Create(ctx context.Context, rule chronograf.AlertRule) (*Task, error)
+	// Update replaces the task at href with the task generated from rule.
+	// If revision is non-empty, it must match the task's current revision
+	// or Update returns ErrConflict without applying rule.
+	Update(ctx context.Context, href string, revision string, rule chronograf.AlertRule) (*Task, error)
+	// Delete removes the task at href.
+	Delete(ctx context.Context, href string) error
+	// Enable starts the task at href.
+	Enable(ctx context.Context, href string) (*Task, error)
+	// Disable stops the task at href.
+	Disable(ctx context.Context, href string) (*Task, error)
+	// Get returns the AlertRule and revision for a single task.
+	Get(ctx context.Context, id string) (chronograf.AlertRule, string, error)
+	// All returns every AlertRule and its revision, keyed by task ID.
+	All(ctx context.Context) (map[string]chronograf.AlertRule, map[string]string, error)
+	// Status returns the status of the task at href.
+	Status(ctx context.Context, href string) (string, error)
+}
+
+// hashRevision derives an opaque revision token from the fields an Update
+// can change. It's a stand-in for a backend-native version/ETag, used by
+// backends (like Kapacitor's task API) that don't expose one of their own.
+func hashRevision(parts ...string) string {
+	h := sha256.New()
+	for _, p := range parts {
+		h.Write([]byte(p))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// BackendType identifies which AlertBackend a rule should be dispatched to.
+type BackendType string
+
+const (
+	// KapacitorBackendType dispatches a rule to a Kapacitor TICKscript task.
+	KapacitorBackendType BackendType = "kapacitor"
+	// AlertmanagerBackendType dispatches a rule to Prometheus Alertmanager.
+	AlertmanagerBackendType BackendType = "alertmanager"
+	// WebhookBackendType dispatches a rule to a generic webhook endpoint.
+	WebhookBackendType BackendType = "webhook"
+	// FluxBackendType dispatches a rule to InfluxDB 2.x as a Flux task.
+	FluxBackendType BackendType = "flux"
+)
+
+// TargetedRule pairs an AlertRule with the backend that should run it. This
+// is how a rule "declares" its backend without Chronograf's core AlertRule
+// type needing to know about Kapacitor, Alertmanager, or webhooks.
+type TargetedRule struct {
+	chronograf.AlertRule
+	Backend BackendType
+}
+
+// ErrUnknownBackend is returned when a TargetedRule names a BackendType the
+// Router has no AlertBackend registered for.
+type ErrUnknownBackend struct {
+	Backend BackendType
+}
+
+func (e ErrUnknownBackend) Error() string {
+	return "kapacitor: unknown alert backend " + string(e.Backend)
+}
+
+// Router dispatches TargetedRules to the AlertBackend registered for their
+// BackendType. It implements no interface itself; callers that accept a
+// bare chronograf.AlertRule should default to KapacitorBackendType.
+type Router struct {
+	Backends map[BackendType]AlertBackend
+}
+
+// NewRouter creates a Router with kapa as the default Kapacitor backend,
+// plus an Alertmanager, webhook, and Flux/InfluxDB 2.x backend for rules
+// that target them. A connection param left empty leaves that backend
+// unregistered, so rules declaring it get ErrUnknownBackend rather than
+// silently falling back to Kapacitor.
+func NewRouter(kapa AlertBackend, alertmanagerURL, webhookURL string, fluxURL, fluxToken, fluxOrgID string) *Router {
+	backends := map[BackendType]AlertBackend{
+		KapacitorBackendType: kapa,
+	}
+	if alertmanagerURL != "" {
+		backends[AlertmanagerBackendType] = NewAlertmanagerBackend(alertmanagerURL)
+	}
+	if webhookURL != "" {
+		backends[WebhookBackendType] = NewWebhookBackend(webhookURL)
+	}
+	if fluxURL != "" {
+		backends[FluxBackendType] = NewFluxBackend(fluxURL, fluxToken, fluxOrgID)
+	}
+	return &Router{Backends: backends}
+}
+
+// backend returns the AlertBackend for rule.Backend, defaulting to
+// KapacitorBackendType when unset.
+func (r *Router) backend(rule TargetedRule) (AlertBackend, error) {
+	return r.backendByType(rule.Backend)
+}
+
+// backendByType returns the AlertBackend registered for backendType,
+// defaulting to KapacitorBackendType when backendType is empty. Unlike
+// Create/Update, the rest of AlertBackend's methods take only a href or id,
+// which isn't enough on its own to say which backend wrote it, so callers
+// must say which backend they mean, the same way TargetedRule does for
+// Create/Update.
+func (r *Router) backendByType(backendType BackendType) (AlertBackend, error) {
+	if backendType == "" {
+		backendType = KapacitorBackendType
+	}
+	be, ok := r.Backends[backendType]
+	if !ok {
+		return nil, ErrUnknownBackend{Backend: backendType}
+	}
+	return be, nil
+}
+
+// Create dispatches rule to the backend it targets.
+func (r *Router) Create(ctx context.Context, rule TargetedRule) (*Task, error) {
+	be, err := r.backend(rule)
+	if err != nil {
+		return nil, err
+	}
+	return be.Create(ctx, rule.AlertRule)
+}
+
+// Update dispatches rule to the backend it targets.
+func (r *Router) Update(ctx context.Context, href, revision string, rule TargetedRule) (*Task, error) {
+	be, err := r.backend(rule)
+	if err != nil {
+		return nil, err
+	}
+	return be.Update(ctx, href, revision, rule.AlertRule)
+}
+
+// Delete dispatches to the named backend and removes the task at href.
+func (r *Router) Delete(ctx context.Context, backendType BackendType, href string) error {
+	be, err := r.backendByType(backendType)
+	if err != nil {
+		return err
+	}
+	return be.Delete(ctx, href)
+}
+
+// Enable dispatches to the named backend and starts the task at href.
+func (r *Router) Enable(ctx context.Context, backendType BackendType, href string) (*Task, error) {
+	be, err := r.backendByType(backendType)
+	if err != nil {
+		return nil, err
+	}
+	return be.Enable(ctx, href)
+}
+
+// Disable dispatches to the named backend and stops the task at href.
+func (r *Router) Disable(ctx context.Context, backendType BackendType, href string) (*Task, error) {
+	be, err := r.backendByType(backendType)
+	if err != nil {
+		return nil, err
+	}
+	return be.Disable(ctx, href)
+}
+
+// Get dispatches to the named backend and returns the AlertRule and
+// revision for a single task.
+func (r *Router) Get(ctx context.Context, backendType BackendType, id string) (chronograf.AlertRule, string, error) {
+	be, err := r.backendByType(backendType)
+	if err != nil {
+		return chronograf.AlertRule{}, "", err
+	}
+	return be.Get(ctx, id)
+}
+
+// Status dispatches to the named backend and returns the status of the task
+// at href.
+func (r *Router) Status(ctx context.Context, backendType BackendType, href string) (string, error) {
+	be, err := r.backendByType(backendType)
+	if err != nil {
+		return "", err
+	}
+	return be.Status(ctx, href)
+}
+
+// All returns every AlertRule and its revision, keyed by task ID, across
+// every backend the Router has registered. Unlike the other methods, All
+// has no single backend to dispatch to, so it fans out to all of them and
+// merges the results.
+func (r *Router) All(ctx context.Context) (map[string]chronograf.AlertRule, map[string]string, error) {
+	rules := map[string]chronograf.AlertRule{}
+	revisions := map[string]string{}
+	for _, be := range r.Backends {
+		beRules, beRevisions, err := be.All(ctx)
+		if err != nil {
+			return nil, nil, err
+		}
+		for id, rule := range beRules {
+			rules[id] = rule
+		}
+		for id, revision := range beRevisions {
+			revisions[id] = revision
+		}
+	}
+	return rules, revisions, nil
+}
+
+var _ AlertBackend = &Client{}
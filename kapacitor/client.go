@@ -12,6 +12,11 @@ import (
 const (
 	// Prefix is prepended to the ID of all alerts
 	Prefix = "chronograf-v1-"
+
+	// DefaultGuaranteedUpdateAttempts bounds how many attempts, in all,
+	// GuaranteedUpdate makes with tryUpdate against a freshly fetched rule
+	// before giving up.
+	DefaultGuaranteedUpdateAttempts = 5
 )
 
 // Client communicates to kapacitor
@@ -52,6 +57,26 @@ type Task struct {
 	HrefOutput string                // Kapacitor relative URI to HTTPOutNode
 	Rule       chronograf.AlertRule  // Rule is the rule that represents this Task
 	TICKScript chronograf.TICKScript // TICKScript is the running script
+	Revision   string                // Revision identifies the TICKscript+status this Task was loaded with
+}
+
+// ErrConflict is returned by Update when the task at href was modified by
+// someone else since its revision was read, so the caller's edit would
+// otherwise silently clobber theirs.
+type ErrConflict struct {
+	ID string
+}
+
+func (e ErrConflict) Error() string {
+	return fmt.Sprintf("kapacitor: task %s was modified since it was loaded", e.ID)
+}
+
+// revisionOf derives a revision token for a task. Kapacitor's task API has
+// no version or ETag field of its own, so Chronograf hashes the fields
+// Update can change: any concurrent edit changes the hash, which is all a
+// compare-and-swap needs even though it isn't a monotonic index.
+func revisionOf(script chronograf.TICKScript, status client.TaskStatus) string {
+	return hashRevision(status.String(), string(script))
 }
 
 // Href returns the link to a kapacitor task given an id
@@ -100,6 +125,7 @@ func (c *Client) Create(ctx context.Context, rule chronograf.AlertRule) (*Task,
 		HrefOutput: c.HrefOutput(kapaID),
 		TICKScript: script,
 		Rule:       c.Reverse(kapaID, script),
+		Revision:   revisionOf(script, client.Enabled),
 	}, nil
 }
 
@@ -127,11 +153,13 @@ func (c *Client) updateStatus(ctx context.Context, href string, status client.Ta
 		return nil, err
 	}
 
+	script := chronograf.TICKScript(task.TICKscript)
 	return &Task{
 		ID:         task.ID,
 		Href:       task.Link.Href,
 		HrefOutput: c.HrefOutput(task.ID),
-		TICKScript: chronograf.TICKScript(task.TICKscript),
+		TICKScript: script,
+		Revision:   revisionOf(script, task.Status),
 	}, nil
 }
 
@@ -183,21 +211,23 @@ func (c *Client) Status(ctx context.Context, href string) (string, error) {
 	return task.Status.String(), nil
 }
 
-// All returns all tasks in kapacitor
-func (c *Client) All(ctx context.Context) (map[string]chronograf.AlertRule, error) {
+// All returns all tasks in kapacitor, along with each task's revision so a
+// later Update can be made conditional on nothing having changed it since.
+func (c *Client) All(ctx context.Context) (map[string]chronograf.AlertRule, map[string]string, error) {
 	kapa, err := c.kapaClient(c.URL, c.Username, c.Password)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	// Only get the status, id and link section back
 	opts := &client.ListTasksOptions{}
 	tasks, err := kapa.ListTasks(opts)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	alerts := map[string]chronograf.AlertRule{}
+	revisions := map[string]string{}
 	for _, task := range tasks {
 		script := chronograf.TICKScript(task.TICKscript)
 		if rule, err := Reverse(script); err != nil {
@@ -211,8 +241,9 @@ func (c *Client) All(ctx context.Context) (map[string]chronograf.AlertRule, erro
 			rule.TICKScript = script
 			alerts[task.ID] = rule
 		}
+		revisions[task.ID] = revisionOf(script, task.Status)
 	}
-	return alerts, nil
+	return alerts, revisions, nil
 }
 
 // Reverse builds a chronograf.AlertRule and its QueryConfig from a tickscript
@@ -231,29 +262,44 @@ func (c *Client) Reverse(id string, script chronograf.TICKScript) chronograf.Ale
 	return rule
 }
 
-// Get returns a single alert in kapacitor
-func (c *Client) Get(ctx context.Context, id string) (chronograf.AlertRule, error) {
+// Get returns a single alert in kapacitor, along with its revision so a
+// later Update can be made conditional on nothing having changed it since.
+func (c *Client) Get(ctx context.Context, id string) (chronograf.AlertRule, string, error) {
 	kapa, err := c.kapaClient(c.URL, c.Username, c.Password)
 	if err != nil {
-		return chronograf.AlertRule{}, err
+		return chronograf.AlertRule{}, "", err
 	}
 	href := c.Href(id)
 	task, err := kapa.Task(client.Link{Href: href}, nil)
 	if err != nil {
-		return chronograf.AlertRule{}, chronograf.ErrAlertNotFound
+		return chronograf.AlertRule{}, "", chronograf.ErrAlertNotFound
 	}
 
 	script := chronograf.TICKScript(task.TICKscript)
-	return c.Reverse(task.ID, script), nil
+	return c.Reverse(task.ID, script), revisionOf(script, task.Status), nil
 }
 
-// Update changes the tickscript of a given id.
-func (c *Client) Update(ctx context.Context, href string, rule chronograf.AlertRule) (*Task, error) {
+// Update changes the tickscript of a given id. If revision is non-empty, it
+// must match the task's current revision or Update returns ErrConflict
+// without applying rule, so a concurrent edit elsewhere isn't silently
+// clobbered. Pass an empty revision to skip the check.
+func (c *Client) Update(ctx context.Context, href string, revision string, rule chronograf.AlertRule) (*Task, error) {
 	kapa, err := c.kapaClient(c.URL, c.Username, c.Password)
 	if err != nil {
 		return nil, err
 	}
 
+	if revision != "" {
+		current, err := kapa.Task(client.Link{Href: href}, nil)
+		if err != nil {
+			return nil, err
+		}
+		currentRevision := revisionOf(chronograf.TICKScript(current.TICKscript), current.Status)
+		if currentRevision != revision {
+			return nil, ErrConflict{ID: current.ID}
+		}
+	}
+
 	script, err := c.Ticker.Generate(rule)
 	if err != nil {
 		return nil, err
@@ -288,9 +334,46 @@ func (c *Client) Update(ctx context.Context, href string, rule chronograf.AlertR
 		HrefOutput: c.HrefOutput(task.ID),
 		TICKScript: script,
 		Rule:       c.Reverse(task.ID, script),
+		Revision:   revisionOf(script, client.Enabled),
 	}, nil
 }
 
+// GuaranteedUpdate performs a compare-and-swap Update against href, retrying
+// whenever a concurrent edit causes a conflict, up to attempts attempts in
+// all. On
+// each attempt it re-fetches the current rule and revision, calls
+// tryUpdate to compute the rule to apply against that current state, and
+// Updates with the freshly read revision. It mirrors the retry loop
+// Kubernetes' storage.Interface.GuaranteedUpdate uses for the same reason:
+// tryUpdate may need to re-derive its result (e.g. merge) once it sees what
+// actually changed underneath it.
+func (c *Client) GuaranteedUpdate(ctx context.Context, href, id string, attempts int, tryUpdate func(current chronograf.AlertRule) (chronograf.AlertRule, error)) (*Task, error) {
+	if attempts <= 0 {
+		attempts = DefaultGuaranteedUpdateAttempts
+	}
+
+	var lastErr error
+	for i := 0; i < attempts; i++ {
+		current, revision, err := c.Get(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+
+		updated, err := tryUpdate(current)
+		if err != nil {
+			return nil, err
+		}
+
+		task, err := c.Update(ctx, href, revision, updated)
+		if _, ok := err.(ErrConflict); ok {
+			lastErr = err
+			continue
+		}
+		return task, err
+	}
+	return nil, lastErr
+}
+
 func toTask(q *chronograf.QueryConfig) client.TaskType {
 	if q == nil || q.RawText == nil || *q.RawText == "" {
 		return client.StreamTask
@@ -0,0 +1,249 @@
+package kapacitor
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/influxdata/chronograf"
+	"github.com/influxdata/chronograf/uuid"
+	client "github.com/influxdata/kapacitor/client/v1"
+)
+
+// fakeKapaClient is an in-memory KapaClient used by tests that exercise
+// Client's revision/CAS and pagination logic without a running Kapacitor.
+type fakeKapaClient struct {
+	mu    sync.Mutex
+	tasks map[string]client.Task // keyed by Link.Href
+	order []string               // href insertion order, for deterministic paging
+}
+
+func newFakeKapaClient() *fakeKapaClient {
+	return &fakeKapaClient{tasks: map[string]client.Task{}}
+}
+
+func (f *fakeKapaClient) CreateTask(opt client.CreateTaskOptions) (client.Task, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	href := fmt.Sprintf("/kapacitor/v1/tasks/%s", opt.ID)
+	task := client.Task{
+		ID:         opt.ID,
+		Link:       client.Link{Href: href},
+		TICKscript: opt.TICKscript,
+		Status:     opt.Status,
+	}
+	f.tasks[href] = task
+	f.order = append(f.order, href)
+	return task, nil
+}
+
+func (f *fakeKapaClient) Task(link client.Link, opt *client.TaskOptions) (client.Task, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	task, ok := f.tasks[link.Href]
+	if !ok {
+		return client.Task{}, fmt.Errorf("no task at %s", link.Href)
+	}
+	return task, nil
+}
+
+func (f *fakeKapaClient) ListTasks(opt *client.ListTasksOptions) ([]client.Task, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	offset := 0
+	limit := len(f.order)
+	if opt != nil {
+		offset = opt.Offset
+		if opt.Limit > 0 {
+			limit = opt.Limit
+		}
+	}
+
+	var tasks []client.Task
+	for i := offset; i < len(f.order) && len(tasks) < limit; i++ {
+		tasks = append(tasks, f.tasks[f.order[i]])
+	}
+	return tasks, nil
+}
+
+func (f *fakeKapaClient) UpdateTask(link client.Link, opt client.UpdateTaskOptions) (client.Task, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	task, ok := f.tasks[link.Href]
+	if !ok {
+		return client.Task{}, fmt.Errorf("no task at %s", link.Href)
+	}
+	if opt.TICKscript != "" {
+		task.TICKscript = opt.TICKscript
+	}
+	task.Status = opt.Status
+	f.tasks[link.Href] = task
+	return task, nil
+}
+
+func (f *fakeKapaClient) DeleteTask(link client.Link) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.tasks[link.Href]; !ok {
+		return fmt.Errorf("no task at %s", link.Href)
+	}
+	delete(f.tasks, link.Href)
+	for i, href := range f.order {
+		if href == link.Href {
+			f.order = append(f.order[:i], f.order[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+// stubTicker generates a deterministic, unparseable-by-design script so
+// tests don't depend on Reverse's TICKscript grammar.
+type stubTicker struct{}
+
+func (stubTicker) Generate(rule chronograf.AlertRule) (chronograf.TICKScript, error) {
+	return chronograf.TICKScript(fmt.Sprintf("-- %s --", rule.Name)), nil
+}
+
+func newTestClient(kapa KapaClient) *Client {
+	return &Client{
+		URL:        "http://kapacitor.example.com",
+		ID:         &uuid.V4{},
+		Ticker:     stubTicker{},
+		kapaClient: func(url, username, password string) (KapaClient, error) { return kapa, nil },
+	}
+}
+
+func testRule(name string) chronograf.AlertRule {
+	return chronograf.AlertRule{
+		Name:  name,
+		Query: &chronograf.QueryConfig{Database: "telegraf", RetentionPolicy: "autogen"},
+	}
+}
+
+func TestClientCreateAndGet(t *testing.T) {
+	c := newTestClient(newFakeKapaClient())
+
+	task, err := c.Create(context.Background(), testRule("cpu high"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if task.Revision == "" {
+		t.Fatalf("expected Create to populate a revision")
+	}
+
+	_, revision, err := c.Get(context.Background(), task.ID)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if revision != task.Revision {
+		t.Fatalf("expected Get's revision %q to match Create's %q", revision, task.Revision)
+	}
+}
+
+func TestClientUpdateDetectsConflict(t *testing.T) {
+	c := newTestClient(newFakeKapaClient())
+
+	task, err := c.Create(context.Background(), testRule("cpu high"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Someone else disables the task, which changes its revision.
+	if _, err := c.Disable(context.Background(), task.Href); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	_, err = c.Update(context.Background(), task.Href, task.Revision, testRule("cpu high, renamed"))
+	if _, ok := err.(ErrConflict); !ok {
+		t.Fatalf("expected ErrConflict for a stale revision, got %v", err)
+	}
+}
+
+func TestClientUpdateSkipsCheckWithoutRevision(t *testing.T) {
+	c := newTestClient(newFakeKapaClient())
+
+	task, err := c.Create(context.Background(), testRule("cpu high"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := c.Disable(context.Background(), task.Href); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, err := c.Update(context.Background(), task.Href, "", testRule("cpu high, renamed")); err != nil {
+		t.Fatalf("expected an empty revision to skip the conflict check, got %v", err)
+	}
+}
+
+func TestGuaranteedUpdateRetriesOnConflict(t *testing.T) {
+	c := newTestClient(newFakeKapaClient())
+
+	task, err := c.Create(context.Background(), testRule("cpu high"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	attempt := 0
+	tryUpdate := func(current chronograf.AlertRule) (chronograf.AlertRule, error) {
+		attempt++
+		if attempt == 1 {
+			// Simulate a concurrent edit landing between our Get and our
+			// Update by disabling the task out from under this attempt.
+			if _, err := c.Disable(context.Background(), task.Href); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		}
+		current.Query = &chronograf.QueryConfig{Database: "telegraf", RetentionPolicy: "autogen"}
+		current.Message = "updated"
+		return current, nil
+	}
+
+	updated, err := c.GuaranteedUpdate(context.Background(), task.Href, task.ID, 3, tryUpdate)
+	if err != nil {
+		t.Fatalf("expected GuaranteedUpdate to recover from one conflict, got %v", err)
+	}
+	if attempt != 2 {
+		t.Fatalf("expected tryUpdate to run twice (conflict then success), ran %d times", attempt)
+	}
+	if updated == nil {
+		t.Fatalf("expected a non-nil Task on success")
+	}
+}
+
+func TestGuaranteedUpdateGivesUpAfterAttempts(t *testing.T) {
+	c := newTestClient(newFakeKapaClient())
+
+	task, err := c.Create(context.Background(), testRule("cpu high"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	enabled := true
+	tryUpdate := func(current chronograf.AlertRule) (chronograf.AlertRule, error) {
+		// Every attempt races a concurrent edit that flips the task's
+		// status, so the revision this attempt read is always stale by
+		// the time its Update runs and it never converges.
+		var err error
+		if enabled {
+			_, err = c.Disable(context.Background(), task.Href)
+		} else {
+			_, err = c.Enable(context.Background(), task.Href)
+		}
+		enabled = !enabled
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		current.Query = &chronograf.QueryConfig{Database: "telegraf", RetentionPolicy: "autogen"}
+		current.Message = "updated"
+		return current, nil
+	}
+
+	if _, err := c.GuaranteedUpdate(context.Background(), task.Href, task.ID, 2, tryUpdate); err == nil {
+		t.Fatalf("expected GuaranteedUpdate to give up after exhausting its attempts")
+	}
+}
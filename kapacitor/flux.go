@@ -0,0 +1,409 @@
+package kapacitor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/influxdata/chronograf"
+)
+
+// FluxTicker generates a Flux task, rather than a TICKscript, for an
+// AlertRule. It implements chronograf.Ticker so it can be swapped in for
+// the Kapacitor Alert ticker wherever a rule targets InfluxDB 2.x.
+type FluxTicker struct{}
+
+// Generate creates a Flux task script using monitor.check for rules with a
+// threshold and monitor.deadman for rules watching for missing data.
+func (t *FluxTicker) Generate(rule chronograf.AlertRule) (chronograf.TICKScript, error) {
+	if rule.Query == nil {
+		return "", fmt.Errorf("must specify a query")
+	}
+
+	every := "1m"
+	if rule.Every != "" {
+		every = rule.Every
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "import \"influxdata/influxdb/monitor\"\n\n")
+	fmt.Fprintf(&buf, "option task = {name: %q, every: %s}\n\n", rule.Name, every)
+	fmt.Fprintf(&buf, "data = from(bucket: %q)\n", rule.Query.Database+"/"+rule.Query.RetentionPolicy)
+	fmt.Fprintf(&buf, "\t|> range(start: -%s)\n\n", every)
+
+	if rule.Trigger == "deadman" {
+		fmt.Fprintf(&buf, "data\n\t|> monitor.deadman(t: -%s)\n", rule.TriggerValues.Period)
+		return chronograf.TICKScript(buf.String()), nil
+	}
+
+	op := fluxComparison(rule.TriggerValues.Operator)
+	fmt.Fprintf(&buf, "data\n\t|> monitor.check(\n")
+	fmt.Fprintf(&buf, "\t\tcrit: (r) => r._value %s %s,\n", op, rule.TriggerValues.Value)
+	fmt.Fprintf(&buf, "\t\tmessageFn: (r) => %q,\n", rule.Message)
+	fmt.Fprintf(&buf, "\t\tdata: data,\n")
+	fmt.Fprintf(&buf, "\t)\n")
+
+	return chronograf.TICKScript(buf.String()), nil
+}
+
+// fluxComparison translates a chronograf trigger operator into the Flux
+// comparison operator used inside monitor.check's crit predicate.
+func fluxComparison(op string) string {
+	switch op {
+	case "greater than":
+		return ">"
+	case "less than":
+		return "<"
+	case "equal to":
+		return "=="
+	case "not equal to":
+		return "!="
+	default:
+		return op
+	}
+}
+
+var (
+	fluxTaskNameRe  = regexp.MustCompile(`name:\s*"([^"]*)"`)
+	fluxTaskEveryRe = regexp.MustCompile(`every:\s*([0-9A-Za-z]+)`)
+	fluxBucketRe    = regexp.MustCompile(`bucket:\s*"([^/"]*)/([^"]*)"`)
+	fluxRangeRe     = regexp.MustCompile(`range\(start:\s*-([0-9A-Za-z]+)\)`)
+	fluxCritRe      = regexp.MustCompile(`r\._value\s*([><=!]+)\s*([0-9.]+)`)
+	fluxDeadmanRe   = regexp.MustCompile(`monitor\.deadman\(t:\s*-([0-9A-Za-z]+)\)`)
+)
+
+// fluxOperator is the inverse of fluxComparison.
+func fluxOperator(op string) string {
+	switch op {
+	case ">":
+		return "greater than"
+	case "<":
+		return "less than"
+	case "==":
+		return "equal to"
+	case "!=":
+		return "not equal to"
+	default:
+		return op
+	}
+}
+
+// ReverseFlux builds a chronograf.AlertRule and its QueryConfig from a Flux
+// task script generated by FluxTicker. It is the Flux counterpart of
+// Reverse, and is necessarily best-effort: a hand-written Flux task that
+// doesn't follow FluxTicker's shape will only partially reverse.
+func ReverseFlux(script chronograf.TICKScript) (chronograf.AlertRule, error) {
+	rule := chronograf.AlertRule{}
+
+	if m := fluxTaskNameRe.FindStringSubmatch(string(script)); m != nil {
+		rule.Name = m[1]
+	}
+	if m := fluxTaskEveryRe.FindStringSubmatch(string(script)); m != nil {
+		rule.Every = m[1]
+	}
+
+	query := &chronograf.QueryConfig{}
+	if m := fluxBucketRe.FindStringSubmatch(string(script)); m != nil {
+		query.Database = m[1]
+		query.RetentionPolicy = m[2]
+	}
+	rule.Query = query
+
+	if m := fluxDeadmanRe.FindStringSubmatch(string(script)); m != nil {
+		rule.Trigger = "deadman"
+		rule.TriggerValues = chronograf.TriggerValues{Period: m[1]}
+		return rule, nil
+	}
+
+	if m := fluxCritRe.FindStringSubmatch(string(script)); m != nil {
+		rule.Trigger = "threshold"
+		rule.TriggerValues = chronograf.TriggerValues{
+			Operator: fluxOperator(m[1]),
+			Value:    m[2],
+		}
+	}
+
+	return rule, nil
+}
+
+// fluxTask is the subset of InfluxDB 2.x's /api/v2/tasks request/response
+// body that Chronograf needs.
+type fluxTask struct {
+	ID     string `json:"id,omitempty"`
+	OrgID  string `json:"orgID"`
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Flux   string `json:"flux"`
+}
+
+// FluxBackend dispatches AlertRules to InfluxDB 2.x as Flux tasks via
+// /api/v2/tasks, rather than to Kapacitor as TICKscript tasks.
+type FluxBackend struct {
+	URL    string
+	Token  string
+	OrgID  string
+	Ticker *FluxTicker
+	Client *http.Client
+}
+
+// NewFluxBackend creates an AlertBackend that targets the InfluxDB 2.x
+// instance at url, authenticating with token and writing tasks into orgID.
+func NewFluxBackend(url, token, orgID string) *FluxBackend {
+	return &FluxBackend{
+		URL:    url,
+		Token:  token,
+		OrgID:  orgID,
+		Ticker: &FluxTicker{},
+		Client: http.DefaultClient,
+	}
+}
+
+func (f *FluxBackend) do(ctx context.Context, method, path string, body interface{}) (*http.Response, error) {
+	var buf bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&buf).Encode(body); err != nil {
+			return nil, err
+		}
+	}
+	req, err := http.NewRequest(method, f.URL+path, &buf)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Token "+f.Token)
+	return f.Client.Do(req)
+}
+
+// Create translates rule into a Flux task and POSTs it to /api/v2/tasks.
+func (f *FluxBackend) Create(ctx context.Context, rule chronograf.AlertRule) (*Task, error) {
+	script, err := f.Ticker.Generate(rule)
+	if err != nil {
+		return nil, err
+	}
+
+	task := fluxTask{
+		OrgID:  f.OrgID,
+		Name:   rule.Name,
+		Status: "active",
+		Flux:   string(script),
+	}
+	resp, err := f.do(ctx, "POST", "/api/v2/tasks", task)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		b, _ := ioutil.ReadAll(resp.Body)
+		return nil, fmt.Errorf("influxdb2: create task failed: %s: %s", resp.Status, string(b))
+	}
+
+	var created fluxTask
+	if err := json.NewDecoder(resp.Body).Decode(&created); err != nil {
+		return nil, err
+	}
+
+	kapaID := Prefix + created.ID
+	rule.ID = kapaID
+	return &Task{
+		ID:         kapaID,
+		Href:       "/api/v2/tasks/" + created.ID,
+		TICKScript: script,
+		Rule:       rule,
+		Revision:   hashRevision("active", string(script)),
+	}, nil
+}
+
+// Update replaces the Flux task at href with the script generated from
+// rule. If revision is non-empty, it must match the task's current
+// revision or Update returns ErrConflict without applying rule.
+func (f *FluxBackend) Update(ctx context.Context, href, revision string, rule chronograf.AlertRule) (*Task, error) {
+	if revision != "" {
+		_, currentRevision, err := f.taskRevision(ctx, href)
+		if err != nil {
+			return nil, err
+		}
+		if currentRevision != revision {
+			return nil, ErrConflict{ID: rule.ID}
+		}
+	}
+
+	script, err := f.Ticker.Generate(rule)
+	if err != nil {
+		return nil, err
+	}
+
+	task := fluxTask{Name: rule.Name, Flux: string(script)}
+	resp, err := f.do(ctx, "PATCH", href, task)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		b, _ := ioutil.ReadAll(resp.Body)
+		return nil, fmt.Errorf("influxdb2: update task failed: %s: %s", resp.Status, string(b))
+	}
+
+	var updated fluxTask
+	if err := json.NewDecoder(resp.Body).Decode(&updated); err != nil {
+		return nil, err
+	}
+
+	return &Task{
+		ID:         rule.ID,
+		Href:       href,
+		TICKScript: script,
+		Rule:       rule,
+		Revision:   hashRevision(updated.Status, string(script)),
+	}, nil
+}
+
+// taskRevision fetches the Flux task at href and derives its revision.
+func (f *FluxBackend) taskRevision(ctx context.Context, href string) (fluxTask, string, error) {
+	resp, err := f.do(ctx, "GET", href, nil)
+	if err != nil {
+		return fluxTask{}, "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fluxTask{}, "", chronograf.ErrAlertNotFound
+	}
+
+	var task fluxTask
+	if err := json.NewDecoder(resp.Body).Decode(&task); err != nil {
+		return fluxTask{}, "", err
+	}
+	return task, hashRevision(task.Status, task.Flux), nil
+}
+
+// Delete removes the Flux task at href.
+func (f *FluxBackend) Delete(ctx context.Context, href string) error {
+	resp, err := f.do(ctx, "DELETE", href, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		b, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("influxdb2: delete task failed: %s: %s", resp.Status, string(b))
+	}
+	return nil
+}
+
+func (f *FluxBackend) setStatus(ctx context.Context, href, status string) (*Task, error) {
+	resp, err := f.do(ctx, "PATCH", href, fluxTask{Status: status})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		b, _ := ioutil.ReadAll(resp.Body)
+		return nil, fmt.Errorf("influxdb2: set status failed: %s: %s", resp.Status, string(b))
+	}
+
+	var updated fluxTask
+	if err := json.NewDecoder(resp.Body).Decode(&updated); err != nil {
+		return nil, err
+	}
+	return &Task{
+		ID:         Prefix + updated.ID,
+		Href:       href,
+		TICKScript: chronograf.TICKScript(updated.Flux),
+		Revision:   hashRevision(updated.Status, updated.Flux),
+	}, nil
+}
+
+// Enable activates the Flux task at href.
+func (f *FluxBackend) Enable(ctx context.Context, href string) (*Task, error) {
+	return f.setStatus(ctx, href, "active")
+}
+
+// Disable deactivates the Flux task at href without deleting it.
+func (f *FluxBackend) Disable(ctx context.Context, href string) (*Task, error) {
+	return f.setStatus(ctx, href, "inactive")
+}
+
+// Get returns a single AlertRule and its revision, reconstructed from a
+// Flux task. id is Prefix-qualified, as Create hands it back, but InfluxDB
+// 2.x's own task IDs aren't, so Prefix is stripped before building the href.
+func (f *FluxBackend) Get(ctx context.Context, id string) (chronograf.AlertRule, string, error) {
+	rawID := strings.TrimPrefix(id, Prefix)
+	task, revision, err := f.taskRevision(ctx, "/api/v2/tasks/"+rawID)
+	if err != nil {
+		return chronograf.AlertRule{}, "", err
+	}
+
+	script := chronograf.TICKScript(task.Flux)
+	rule, err := ReverseFlux(script)
+	if err != nil {
+		return chronograf.AlertRule{ID: id, Name: task.Name, TICKScript: script}, revision, nil
+	}
+	rule.ID = id
+	rule.TICKScript = script
+	return rule, revision, nil
+}
+
+// All returns every Flux task in the org, reversed back into AlertRules,
+// along with each one's revision.
+func (f *FluxBackend) All(ctx context.Context) (map[string]chronograf.AlertRule, map[string]string, error) {
+	resp, err := f.do(ctx, "GET", "/api/v2/tasks?orgID="+f.OrgID, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		b, _ := ioutil.ReadAll(resp.Body)
+		return nil, nil, fmt.Errorf("influxdb2: list tasks failed: %s: %s", resp.Status, string(b))
+	}
+
+	var body struct {
+		Tasks []fluxTask `json:"tasks"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, nil, err
+	}
+
+	alerts := map[string]chronograf.AlertRule{}
+	revisions := map[string]string{}
+	for _, task := range body.Tasks {
+		// Keyed by Prefix+task.ID to match the ID Create and Get hand back,
+		// so a caller looking up one of All's keys finds it.
+		id := Prefix + task.ID
+		script := chronograf.TICKScript(task.Flux)
+		if rule, err := ReverseFlux(script); err != nil {
+			alerts[id] = chronograf.AlertRule{ID: id, Name: task.Name, TICKScript: script}
+		} else {
+			rule.ID = id
+			rule.TICKScript = script
+			alerts[id] = rule
+		}
+		revisions[id] = hashRevision(task.Status, task.Flux)
+	}
+	return alerts, revisions, nil
+}
+
+// Status returns "active" or "inactive" for the Flux task at href.
+func (f *FluxBackend) Status(ctx context.Context, href string) (string, error) {
+	resp, err := f.do(ctx, "GET", href, nil)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return "", chronograf.ErrAlertNotFound
+	}
+
+	var task fluxTask
+	if err := json.NewDecoder(resp.Body).Decode(&task); err != nil {
+		return "", err
+	}
+	return task.Status, nil
+}
+
+var _ AlertBackend = &FluxBackend{}
+var _ chronograf.Ticker = &FluxTicker{}
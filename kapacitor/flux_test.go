@@ -0,0 +1,310 @@
+package kapacitor
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/influxdata/chronograf"
+)
+
+// fakeInfluxDB2 is a minimal in-memory stand-in for the InfluxDB 2.x
+// /api/v2/tasks endpoints FluxBackend talks to.
+type fakeInfluxDB2 struct {
+	mu     sync.Mutex
+	tasks  map[string]fluxTask // keyed by bare (unprefixed) task ID
+	nextID int
+}
+
+func newFakeInfluxDB2() *httptest.Server {
+	fi := &fakeInfluxDB2{tasks: map[string]fluxTask{}}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fi.mu.Lock()
+		defer fi.mu.Unlock()
+
+		if r.URL.Path == "/api/v2/tasks" {
+			switch r.Method {
+			case "POST":
+				var task fluxTask
+				if err := json.NewDecoder(r.Body).Decode(&task); err != nil {
+					w.WriteHeader(http.StatusBadRequest)
+					return
+				}
+				fi.nextID++
+				task.ID = strconv.Itoa(fi.nextID)
+				fi.tasks[task.ID] = task
+				json.NewEncoder(w).Encode(task)
+				return
+			case "GET":
+				var body struct {
+					Tasks []fluxTask `json:"tasks"`
+				}
+				for _, task := range fi.tasks {
+					body.Tasks = append(body.Tasks, task)
+				}
+				json.NewEncoder(w).Encode(body)
+				return
+			}
+		}
+
+		id := strings.TrimPrefix(r.URL.Path, "/api/v2/tasks/")
+		switch r.Method {
+		case "GET":
+			task, ok := fi.tasks[id]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			json.NewEncoder(w).Encode(task)
+		case "PATCH":
+			task, ok := fi.tasks[id]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			var patch fluxTask
+			if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			if patch.Flux != "" {
+				task.Flux = patch.Flux
+			}
+			if patch.Status != "" {
+				task.Status = patch.Status
+			}
+			if patch.Name != "" {
+				task.Name = patch.Name
+			}
+			fi.tasks[id] = task
+			json.NewEncoder(w).Encode(task)
+		case "DELETE":
+			if _, ok := fi.tasks[id]; !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			delete(fi.tasks, id)
+			w.WriteHeader(http.StatusOK)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	}))
+}
+
+func TestFluxTickerGenerateThreshold(t *testing.T) {
+	rule := chronograf.AlertRule{
+		Name:    "cpu high",
+		Every:   "5m",
+		Trigger: "threshold",
+		Query:   &chronograf.QueryConfig{Database: "telegraf", RetentionPolicy: "autogen"},
+		TriggerValues: chronograf.TriggerValues{
+			Operator: "greater than",
+			Value:    "90",
+		},
+		Message: "cpu is high",
+	}
+
+	ticker := &FluxTicker{}
+	script, err := ticker.Generate(rule)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, want := range []string{
+		`name: "cpu high"`,
+		`every: 5m`,
+		`bucket: "telegraf/autogen"`,
+		`r._value > 90`,
+	} {
+		if !strings.Contains(string(script), want) {
+			t.Fatalf("expected script to contain %q, got:\n%s", want, script)
+		}
+	}
+}
+
+func TestFluxTickerGenerateDeadman(t *testing.T) {
+	rule := chronograf.AlertRule{
+		Name:          "no data",
+		Trigger:       "deadman",
+		Query:         &chronograf.QueryConfig{Database: "telegraf", RetentionPolicy: "autogen"},
+		TriggerValues: chronograf.TriggerValues{Period: "10m"},
+	}
+
+	ticker := &FluxTicker{}
+	script, err := ticker.Generate(rule)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(string(script), "monitor.deadman(t: -10m)") {
+		t.Fatalf("expected deadman script, got:\n%s", script)
+	}
+}
+
+func TestFluxTickerGenerateRequiresQuery(t *testing.T) {
+	ticker := &FluxTicker{}
+	if _, err := ticker.Generate(chronograf.AlertRule{Name: "no query"}); err == nil {
+		t.Fatalf("expected an error when rule.Query is nil")
+	}
+}
+
+func TestReverseFluxThresholdRoundTrip(t *testing.T) {
+	rule := chronograf.AlertRule{
+		Name:    "cpu high",
+		Every:   "5m",
+		Trigger: "threshold",
+		Query:   &chronograf.QueryConfig{Database: "telegraf", RetentionPolicy: "autogen"},
+		TriggerValues: chronograf.TriggerValues{
+			Operator: "greater than",
+			Value:    "90",
+		},
+		Message: "cpu is high",
+	}
+
+	ticker := &FluxTicker{}
+	script, err := ticker.Generate(rule)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	reversed, err := ReverseFlux(script)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if reversed.Name != rule.Name {
+		t.Fatalf("expected Name %q, got %q", rule.Name, reversed.Name)
+	}
+	if reversed.Every != rule.Every {
+		t.Fatalf("expected Every %q, got %q", rule.Every, reversed.Every)
+	}
+	if reversed.Query.Database != rule.Query.Database || reversed.Query.RetentionPolicy != rule.Query.RetentionPolicy {
+		t.Fatalf("expected bucket %s/%s, got %s/%s", rule.Query.Database, rule.Query.RetentionPolicy, reversed.Query.Database, reversed.Query.RetentionPolicy)
+	}
+	if reversed.Trigger != "threshold" {
+		t.Fatalf("expected Trigger threshold, got %q", reversed.Trigger)
+	}
+	if reversed.TriggerValues.Operator != rule.TriggerValues.Operator || reversed.TriggerValues.Value != rule.TriggerValues.Value {
+		t.Fatalf("expected TriggerValues %+v, got %+v", rule.TriggerValues, reversed.TriggerValues)
+	}
+}
+
+func TestReverseFluxDeadmanRoundTrip(t *testing.T) {
+	rule := chronograf.AlertRule{
+		Name:          "no data",
+		Trigger:       "deadman",
+		Query:         &chronograf.QueryConfig{Database: "telegraf", RetentionPolicy: "autogen"},
+		TriggerValues: chronograf.TriggerValues{Period: "10m"},
+	}
+
+	ticker := &FluxTicker{}
+	script, err := ticker.Generate(rule)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	reversed, err := ReverseFlux(script)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if reversed.Trigger != "deadman" {
+		t.Fatalf("expected Trigger deadman, got %q", reversed.Trigger)
+	}
+	if reversed.TriggerValues.Period != rule.TriggerValues.Period {
+		t.Fatalf("expected Period %q, got %q", rule.TriggerValues.Period, reversed.TriggerValues.Period)
+	}
+}
+
+func TestFluxComparisonRoundTrip(t *testing.T) {
+	for _, op := range []string{"greater than", "less than", "equal to", "not equal to"} {
+		if got := fluxOperator(fluxComparison(op)); got != op {
+			t.Fatalf("expected %q to round trip, got %q", op, got)
+		}
+	}
+}
+
+// TestFluxBackendCreateIDRoundTripsThroughGetAndAll pins the fix for an ID
+// scheme mismatch: Create returned Prefix+task.ID, but Get built its href
+// from the id verbatim (no Prefix stripped) and All keyed its map by the
+// bare task.ID (no Prefix added), so a caller using Create's ID got a 404
+// from Get and a miss from All.
+func TestFluxBackendCreateIDRoundTripsThroughGetAndAll(t *testing.T) {
+	srv := newFakeInfluxDB2()
+	defer srv.Close()
+	f := NewFluxBackend(srv.URL, "token", "org1")
+
+	created, err := f.Create(context.Background(), chronograf.AlertRule{
+		Name:    "cpu high",
+		Trigger: "threshold",
+		Query:   &chronograf.QueryConfig{Database: "telegraf", RetentionPolicy: "autogen"},
+		TriggerValues: chronograf.TriggerValues{
+			Operator: "greater than",
+			Value:    "90",
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, _, err := f.Get(context.Background(), created.ID); err != nil {
+		t.Fatalf("expected Get(Create's ID) to find the task, got %v", err)
+	}
+
+	alerts, _, err := f.All(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := alerts[created.ID]; !ok {
+		t.Fatalf("expected All to key its map by Create's ID %q, got keys %v", created.ID, mapKeys(alerts))
+	}
+}
+
+func mapKeys(m map[string]chronograf.AlertRule) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// TestFluxBackendEnableDisablePopulateRevision pins the fix for Enable and
+// Disable (setStatus) leaving Revision zero-valued, which let a later
+// Update with that empty revision silently skip its CAS conflict check.
+func TestFluxBackendEnableDisablePopulateRevision(t *testing.T) {
+	srv := newFakeInfluxDB2()
+	defer srv.Close()
+	f := NewFluxBackend(srv.URL, "token", "org1")
+
+	created, err := f.Create(context.Background(), chronograf.AlertRule{
+		Name:          "cpu high",
+		Trigger:       "deadman",
+		Query:         &chronograf.QueryConfig{Database: "telegraf", RetentionPolicy: "autogen"},
+		TriggerValues: chronograf.TriggerValues{Period: "5m"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	disabled, err := f.Disable(context.Background(), created.Href)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if disabled.Revision == "" {
+		t.Fatalf("expected Disable to populate a revision")
+	}
+
+	enabled, err := f.Enable(context.Background(), created.Href)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if enabled.Revision == "" {
+		t.Fatalf("expected Enable to populate a revision")
+	}
+	if enabled.Revision == disabled.Revision {
+		t.Fatalf("expected Enable's revision to differ from Disable's, since status changed")
+	}
+}
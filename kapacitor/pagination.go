@@ -0,0 +1,139 @@
+package kapacitor
+
+import (
+	"context"
+
+	"github.com/influxdata/chronograf"
+	client "github.com/influxdata/kapacitor/client/v1"
+)
+
+// defaultPageSize bounds how many tasks AllPaged requests per page when the
+// caller doesn't specify one.
+const defaultPageSize = 100
+
+// ListOptions customizes a paginated listing of tasks.
+type ListOptions struct {
+	// Limit bounds how many tasks a single page returns. Defaults to
+	// defaultPageSize when <= 0.
+	Limit int
+	// Offset skips this many tasks from the start of the list.
+	Offset int
+	// Fields restricts which task fields Kapacitor returns. When Fields is
+	// non-empty and doesn't include "script", Reverse is skipped entirely:
+	// a caller that only needs IDs/status doesn't pay to transfer or parse
+	// every TICKscript.
+	Fields []string
+}
+
+// Page is one page of a paginated task listing.
+type Page struct {
+	// Alerts holds this page's tasks, reversed into AlertRules and keyed
+	// by task ID.
+	Alerts map[string]chronograf.AlertRule
+	// Offset is the offset the next page should request.
+	Offset int
+	// More is true if another page may still follow this one.
+	More bool
+}
+
+func hasField(fields []string, name string) bool {
+	for _, f := range fields {
+		if f == name {
+			return true
+		}
+	}
+	return false
+}
+
+// AllPaged returns one page of tasks, using Kapacitor's limit/offset list
+// parameters so callers aren't forced to load every task into memory at
+// once.
+func (c *Client) AllPaged(ctx context.Context, opts ListOptions) (Page, error) {
+	kapa, err := c.kapaClient(c.URL, c.Username, c.Password)
+	if err != nil {
+		return Page{}, err
+	}
+
+	limit := opts.Limit
+	if limit <= 0 {
+		limit = defaultPageSize
+	}
+	wantScript := len(opts.Fields) == 0 || hasField(opts.Fields, "script")
+
+	tasks, err := kapa.ListTasks(&client.ListTasksOptions{
+		Fields: opts.Fields,
+		Limit:  limit,
+		Offset: opts.Offset,
+	})
+	if err != nil {
+		return Page{}, err
+	}
+
+	alerts := map[string]chronograf.AlertRule{}
+	for _, task := range tasks {
+		if !wantScript {
+			alerts[task.ID] = chronograf.AlertRule{ID: task.ID, Name: task.ID}
+			continue
+		}
+
+		script := chronograf.TICKScript(task.TICKscript)
+		if rule, err := Reverse(script); err != nil {
+			alerts[task.ID] = chronograf.AlertRule{
+				ID:         task.ID,
+				Name:       task.ID,
+				TICKScript: script,
+			}
+		} else {
+			rule.ID = task.ID
+			rule.TICKScript = script
+			alerts[task.ID] = rule
+		}
+	}
+
+	return Page{
+		Alerts: alerts,
+		Offset: opts.Offset + len(tasks),
+		More:   len(tasks) == limit,
+	}, nil
+}
+
+// Stream pages through all tasks internally via AllPaged, emitting each
+// AlertRule on the returned channel as it arrives. Both channels are
+// closed when the listing completes, ctx is canceled, or an error occurs.
+func (c *Client) Stream(ctx context.Context, opts ListOptions) (<-chan chronograf.AlertRule, <-chan error) {
+	rules := make(chan chronograf.AlertRule)
+	errc := make(chan error, 1)
+
+	go func() {
+		defer close(rules)
+		defer close(errc)
+
+		offset := opts.Offset
+		for {
+			pageOpts := opts
+			pageOpts.Offset = offset
+
+			page, err := c.AllPaged(ctx, pageOpts)
+			if err != nil {
+				errc <- err
+				return
+			}
+
+			for _, rule := range page.Alerts {
+				select {
+				case rules <- rule:
+				case <-ctx.Done():
+					errc <- ctx.Err()
+					return
+				}
+			}
+
+			if !page.More {
+				return
+			}
+			offset = page.Offset
+		}
+	}()
+
+	return rules, errc
+}
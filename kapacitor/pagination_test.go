@@ -0,0 +1,116 @@
+package kapacitor
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	client "github.com/influxdata/kapacitor/client/v1"
+)
+
+func seedTasks(t *testing.T, kapa *fakeKapaClient, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		_, err := kapa.CreateTask(client.CreateTaskOptions{
+			ID:         fmt.Sprintf("task-%d", i),
+			TICKscript: "-- unparseable --",
+			Status:     client.Enabled,
+		})
+		if err != nil {
+			t.Fatalf("unexpected error seeding task %d: %v", i, err)
+		}
+	}
+}
+
+func TestClientAllPagedBoundaries(t *testing.T) {
+	kapa := newFakeKapaClient()
+	seedTasks(t, kapa, 5)
+	c := newTestClient(kapa)
+
+	page, err := c.AllPaged(context.Background(), ListOptions{Limit: 2})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(page.Alerts) != 2 {
+		t.Fatalf("expected 2 alerts in first page, got %d", len(page.Alerts))
+	}
+	if !page.More {
+		t.Fatalf("expected More to be true with 3 tasks remaining")
+	}
+	if page.Offset != 2 {
+		t.Fatalf("expected next Offset 2, got %d", page.Offset)
+	}
+
+	page, err = c.AllPaged(context.Background(), ListOptions{Limit: 2, Offset: page.Offset})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(page.Alerts) != 2 {
+		t.Fatalf("expected 2 alerts in second page, got %d", len(page.Alerts))
+	}
+	if !page.More {
+		t.Fatalf("expected More to be true with 1 task remaining")
+	}
+
+	page, err = c.AllPaged(context.Background(), ListOptions{Limit: 2, Offset: page.Offset})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(page.Alerts) != 1 {
+		t.Fatalf("expected 1 alert in final page, got %d", len(page.Alerts))
+	}
+	if page.More {
+		t.Fatalf("expected More to be false on the final, partial page")
+	}
+}
+
+func TestClientAllPagedSkipsScriptWithoutField(t *testing.T) {
+	kapa := newFakeKapaClient()
+	seedTasks(t, kapa, 1)
+	c := newTestClient(kapa)
+
+	page, err := c.AllPaged(context.Background(), ListOptions{Fields: []string{"status"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, rule := range page.Alerts {
+		if rule.TICKScript != "" {
+			t.Fatalf("expected TICKScript to be skipped when script field isn't requested, got %q", rule.TICKScript)
+		}
+	}
+}
+
+func TestClientAllPagedIncludesScriptByDefault(t *testing.T) {
+	kapa := newFakeKapaClient()
+	seedTasks(t, kapa, 1)
+	c := newTestClient(kapa)
+
+	page, err := c.AllPaged(context.Background(), ListOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, rule := range page.Alerts {
+		if rule.TICKScript == "" {
+			t.Fatalf("expected TICKScript to be populated when no Fields are set")
+		}
+	}
+}
+
+func TestClientStreamEmitsAllRulesAcrossPages(t *testing.T) {
+	kapa := newFakeKapaClient()
+	seedTasks(t, kapa, 5)
+	c := newTestClient(kapa)
+
+	rules, errc := c.Stream(context.Background(), ListOptions{Limit: 2})
+
+	count := 0
+	for range rules {
+		count++
+	}
+	if err := <-errc; err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if count != 5 {
+		t.Fatalf("expected Stream to emit all 5 rules across pages, got %d", count)
+	}
+}
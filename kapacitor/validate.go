@@ -0,0 +1,120 @@
+package kapacitor
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+
+	"github.com/influxdata/chronograf"
+	client "github.com/influxdata/kapacitor/client/v1"
+)
+
+// maxValidateDeleteAttempts bounds how many delete attempts, in all, Validate
+// makes on its disabled dry-run task before giving up and surfacing the
+// leaked ID to the caller instead of losing track of it silently.
+const maxValidateDeleteAttempts = 3
+
+// Diagnostic describes one issue found while validating a rule's generated
+// TICKscript, positioned so the UI can annotate the script editor.
+type Diagnostic struct {
+	Severity string `json:"severity"` // "error" or "warning"
+	Message  string `json:"message"`
+	Line     int    `json:"line"`
+	Column   int    `json:"column"`
+}
+
+// kapaDiagnosticRe matches Kapacitor's "line:column: message" error format.
+var kapaDiagnosticRe = regexp.MustCompile(`(\d+):(\d+):\s*(.+)`)
+
+// diagnosticFromErr turns a Kapacitor error into a Diagnostic, recovering
+// line/column information when Kapacitor's message includes it.
+func diagnosticFromErr(err error) Diagnostic {
+	msg := err.Error()
+	if m := kapaDiagnosticRe.FindStringSubmatch(msg); m != nil {
+		line, _ := strconv.Atoi(m[1])
+		col, _ := strconv.Atoi(m[2])
+		return Diagnostic{Severity: "error", Message: m[3], Line: line, Column: col}
+	}
+	return Diagnostic{Severity: "error", Message: msg}
+}
+
+// Validate compiles rule to a TICKscript and has Kapacitor parse and type-
+// check it without leaving a running task behind, so the UI can surface
+// errors before the user commits. Kapacitor has no separate dry-run
+// endpoint, so validation creates a disabled task and deletes it
+// immediately; any error from creation is returned as a Diagnostic instead
+// of as err, since a bad script is an expected validation outcome rather
+// than a transport failure. If the cleanup delete itself fails, Validate
+// retries it, making up to maxValidateDeleteAttempts attempts in all; if it
+// still hasn't succeeded, the dry-run task's ID is surfaced in the returned
+// error rather than left to leak silently.
+func (c *Client) Validate(ctx context.Context, rule chronograf.AlertRule) (chronograf.TICKScript, []Diagnostic, error) {
+	kapa, err := c.kapaClient(c.URL, c.Username, c.Password)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if rule.Query == nil {
+		return "", []Diagnostic{{Severity: "error", Message: "must specify a query"}}, nil
+	}
+
+	script, err := c.Ticker.Generate(rule)
+	if err != nil {
+		return "", []Diagnostic{{Severity: "error", Message: err.Error()}}, nil
+	}
+
+	id, err := c.ID.Generate()
+	if err != nil {
+		return script, nil, err
+	}
+	kapaID := Prefix + "validate-" + id
+
+	task, err := kapa.CreateTask(client.CreateTaskOptions{
+		ID:         kapaID,
+		Type:       toTask(rule.Query),
+		DBRPs:      []client.DBRP{{Database: rule.Query.Database, RetentionPolicy: rule.Query.RetentionPolicy}},
+		TICKscript: string(script),
+		Status:     client.Disabled,
+	})
+	if err != nil {
+		return script, []Diagnostic{diagnosticFromErr(err)}, nil
+	}
+
+	var deleteErr error
+	for attempt := 0; attempt < maxValidateDeleteAttempts; attempt++ {
+		if deleteErr = kapa.DeleteTask(client.Link{Href: task.Link.Href}); deleteErr == nil {
+			break
+		}
+	}
+	if deleteErr != nil {
+		return script, nil, fmt.Errorf("kapacitor: validation task %s could not be deleted and has leaked: %v", kapaID, deleteErr)
+	}
+
+	return script, nil, nil
+}
+
+// Preview is the TICKscript a rule would generate, plus a round trip of
+// that script back through Reverse, so a caller can see what fields
+// Chronograf would and wouldn't preserve on a subsequent edit.
+type Preview struct {
+	TICKScript chronograf.TICKScript
+	Rule       chronograf.AlertRule
+}
+
+// PreviewRule generates rule's TICKscript and reverses it back into an
+// AlertRule without contacting Kapacitor at all.
+func (c *Client) PreviewRule(ctx context.Context, rule chronograf.AlertRule) (Preview, error) {
+	script, err := c.Ticker.Generate(rule)
+	if err != nil {
+		return Preview{}, err
+	}
+
+	roundTripped, err := Reverse(script)
+	if err != nil {
+		return Preview{TICKScript: script}, nil
+	}
+	roundTripped.ID = rule.ID
+	roundTripped.TICKScript = script
+	return Preview{TICKScript: script, Rule: roundTripped}, nil
+}
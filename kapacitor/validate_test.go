@@ -0,0 +1,161 @@
+package kapacitor
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/influxdata/chronograf"
+	client "github.com/influxdata/kapacitor/client/v1"
+)
+
+// failingTicker always returns err from Generate, so tests can force
+// Validate/PreviewRule down their script-generation-failed path.
+type failingTicker struct{ err error }
+
+func (f failingTicker) Generate(rule chronograf.AlertRule) (chronograf.TICKScript, error) {
+	return "", f.err
+}
+
+func TestClientValidateHappyPath(t *testing.T) {
+	c := newTestClient(newFakeKapaClient())
+
+	script, diags, err := c.Validate(context.Background(), testRule("cpu high"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(diags) != 0 {
+		t.Fatalf("expected no diagnostics for a valid rule, got %+v", diags)
+	}
+	if script == "" {
+		t.Fatalf("expected a generated script")
+	}
+}
+
+// TestClientValidateNilQuery pins the fix for a panic: Validate used to
+// dereference rule.Query unconditionally, which crashed whenever the UI
+// dry-run-validated a rule that's still being edited and has no query yet.
+func TestClientValidateNilQuery(t *testing.T) {
+	c := newTestClient(newFakeKapaClient())
+
+	_, diags, err := c.Validate(context.Background(), chronograf.AlertRule{Name: "no query yet"})
+	if err != nil {
+		t.Fatalf("expected a missing query to be reported as a Diagnostic, not an error: %v", err)
+	}
+	if len(diags) != 1 || diags[0].Severity != "error" {
+		t.Fatalf("expected exactly one error Diagnostic for a missing query, got %+v", diags)
+	}
+}
+
+func TestClientValidateReportsKapaCreateFailureAsDiagnostic(t *testing.T) {
+	c := newTestClient(&failingCreateKapaClient{err: fmt.Errorf("42:7: unexpected token")})
+
+	_, diags, err := c.Validate(context.Background(), testRule("cpu high"))
+	if err != nil {
+		t.Fatalf("expected a rejected script to be reported as a Diagnostic, not an error: %v", err)
+	}
+	if len(diags) != 1 {
+		t.Fatalf("expected exactly one Diagnostic, got %+v", diags)
+	}
+	if diags[0].Line != 42 || diags[0].Column != 7 {
+		t.Fatalf("expected diagnosticFromErr to recover line:column, got %+v", diags[0])
+	}
+}
+
+// failingCreateKapaClient fails every CreateTask call, so Validate's
+// create-disabled-task-then-delete dry run surfaces the failure.
+type failingCreateKapaClient struct {
+	fakeKapaClient
+	err error
+}
+
+func (f *failingCreateKapaClient) CreateTask(opt client.CreateTaskOptions) (client.Task, error) {
+	return client.Task{}, f.err
+}
+
+// failingDeleteKapaClient fails the first failures DeleteTask calls, then
+// succeeds, so tests can exercise Validate's delete-retry loop.
+type failingDeleteKapaClient struct {
+	fakeKapaClient
+	failures int
+	attempts int
+}
+
+func (f *failingDeleteKapaClient) DeleteTask(link client.Link) error {
+	f.attempts++
+	if f.attempts <= f.failures {
+		return fmt.Errorf("delete failed")
+	}
+	return f.fakeKapaClient.DeleteTask(link)
+}
+
+func TestClientValidateRetriesDeleteThenSucceeds(t *testing.T) {
+	kapa := &failingDeleteKapaClient{fakeKapaClient: *newFakeKapaClient(), failures: maxValidateDeleteAttempts - 1}
+	c := newTestClient(kapa)
+
+	_, _, err := c.Validate(context.Background(), testRule("cpu high"))
+	if err != nil {
+		t.Fatalf("expected Validate to recover after retrying delete, got %v", err)
+	}
+	if kapa.attempts != maxValidateDeleteAttempts {
+		t.Fatalf("expected exactly %d delete attempts, got %d", maxValidateDeleteAttempts, kapa.attempts)
+	}
+}
+
+// TestClientValidateSurfacesLeakedTaskAfterExhaustingRetries pins the fix
+// for Validate silently losing track of its dry-run task when cleanup
+// delete kept failing: the error it returns must now name the leaked task
+// so it isn't orphaned unnoticed.
+func TestClientValidateSurfacesLeakedTaskAfterExhaustingRetries(t *testing.T) {
+	kapa := &failingDeleteKapaClient{fakeKapaClient: *newFakeKapaClient(), failures: maxValidateDeleteAttempts}
+	c := newTestClient(kapa)
+
+	_, _, err := c.Validate(context.Background(), testRule("cpu high"))
+	if err == nil {
+		t.Fatalf("expected Validate to report the leaked dry-run task as an error")
+	}
+	if kapa.attempts != maxValidateDeleteAttempts {
+		t.Fatalf("expected exactly %d delete attempts, got %d", maxValidateDeleteAttempts, kapa.attempts)
+	}
+}
+
+func TestDiagnosticFromErrRecoversLineAndColumn(t *testing.T) {
+	d := diagnosticFromErr(fmt.Errorf("3:10: expected identifier"))
+	if d.Line != 3 || d.Column != 10 {
+		t.Fatalf("expected line 3 column 10, got %+v", d)
+	}
+	if d.Message != "expected identifier" {
+		t.Fatalf("expected message %q, got %q", "expected identifier", d.Message)
+	}
+}
+
+func TestDiagnosticFromErrWithoutPosition(t *testing.T) {
+	d := diagnosticFromErr(fmt.Errorf("connection refused"))
+	if d.Line != 0 || d.Column != 0 {
+		t.Fatalf("expected no position info, got %+v", d)
+	}
+	if d.Message != "connection refused" {
+		t.Fatalf("expected message %q, got %q", "connection refused", d.Message)
+	}
+}
+
+func TestClientPreviewRuleGeneratesScript(t *testing.T) {
+	c := newTestClient(newFakeKapaClient())
+
+	preview, err := c.PreviewRule(context.Background(), testRule("cpu high"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if preview.TICKScript == "" {
+		t.Fatalf("expected a generated script")
+	}
+}
+
+func TestClientPreviewRulePropagatesGenerateError(t *testing.T) {
+	c := newTestClient(newFakeKapaClient())
+	c.Ticker = failingTicker{err: fmt.Errorf("bad rule")}
+
+	if _, err := c.PreviewRule(context.Background(), testRule("cpu high")); err == nil {
+		t.Fatalf("expected PreviewRule to propagate a Generate error")
+	}
+}
@@ -0,0 +1,246 @@
+package kapacitor
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/influxdata/chronograf"
+)
+
+// TaskEventType identifies the kind of change a StatusWatcher observed
+// between polls.
+type TaskEventType string
+
+const (
+	// TaskAdded is emitted the first time a StatusWatcher sees a task.
+	TaskAdded TaskEventType = "added"
+	// TaskRemoved is emitted when a previously seen task disappears.
+	TaskRemoved TaskEventType = "removed"
+	// TaskStatusChanged is emitted when a task's enabled/disabled status flips.
+	TaskStatusChanged TaskEventType = "status_changed"
+	// TaskScriptChanged is emitted when a task's TICKscript changes.
+	TaskScriptChanged TaskEventType = "script_changed"
+)
+
+// TaskEvent describes one change a StatusWatcher observed in a task's
+// status or script between polls.
+type TaskEvent struct {
+	Type   TaskEventType
+	ID     string
+	Rule   chronograf.AlertRule
+	Status string
+}
+
+// defaultWatchInterval is used when a StatusWatcher's Interval is unset.
+const defaultWatchInterval = 30 * time.Second
+
+// watcherCacheEntry is what a StatusWatcher remembers about a task between
+// polls.
+type watcherCacheEntry struct {
+	rule    chronograf.AlertRule
+	status  string
+	expires time.Time
+}
+
+// StatusWatcher periodically polls a Client for its tasks and emits the
+// diffs as TaskEvents over channels returned by Subscribe. It also keeps
+// an in-memory cache of each task's rule and status, so HTTP handlers can
+// be served from the cache with a max-age instead of every handler doing
+// its own full ListTasks call against Kapacitor.
+type StatusWatcher struct {
+	// Client is polled for tasks on each tick.
+	Client *Client
+	// Interval is the nominal time between polls; actual polls are
+	// jittered around it so concurrent watchers don't all hit Kapacitor
+	// at once. Defaults to defaultWatchInterval.
+	Interval time.Duration
+	// MaxAge bounds how long a cached entry is served before it's treated
+	// as stale.
+	MaxAge time.Duration
+
+	mu       sync.Mutex
+	cache    map[string]watcherCacheEntry
+	subs     map[int]chan TaskEvent
+	nextSub  int
+	inFlight *refreshResult
+}
+
+// refreshResult is shared by every Refresh call that collapses into a
+// single in-flight poll; done is closed once err is safe to read.
+type refreshResult struct {
+	done chan struct{}
+	err  error
+}
+
+// NewStatusWatcher creates a StatusWatcher polling client every interval,
+// serving cached entries for up to maxAge.
+func NewStatusWatcher(client *Client, interval, maxAge time.Duration) *StatusWatcher {
+	return &StatusWatcher{
+		Client:   client,
+		Interval: interval,
+		MaxAge:   maxAge,
+		cache:    map[string]watcherCacheEntry{},
+		subs:     map[int]chan TaskEvent{},
+	}
+}
+
+// Subscribe returns a channel that receives every TaskEvent the watcher
+// emits from here on, and an unsubscribe func the caller must call once
+// it's done reading (e.g. when a UI client disconnects) so the watcher
+// stops holding a reference to its channel. The channel is buffered; if
+// the subscriber falls behind, further events are dropped rather than
+// blocking the poll loop.
+func (w *StatusWatcher) Subscribe() (<-chan TaskEvent, func()) {
+	ch := make(chan TaskEvent, 16)
+
+	w.mu.Lock()
+	id := w.nextSub
+	w.nextSub++
+	w.subs[id] = ch
+	w.mu.Unlock()
+
+	unsubscribe := func() {
+		w.mu.Lock()
+		delete(w.subs, id)
+		w.mu.Unlock()
+	}
+	return ch, unsubscribe
+}
+
+// Start runs the poll loop until ctx is canceled.
+func (w *StatusWatcher) Start(ctx context.Context) {
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(w.jitteredInterval()):
+				w.Refresh(ctx)
+			}
+		}
+	}()
+}
+
+// jitteredInterval returns Interval (or defaultWatchInterval) plus up to
+// 50% extra, so multiple StatusWatchers started at the same time don't
+// converge on polling Kapacitor in lockstep.
+func (w *StatusWatcher) jitteredInterval() time.Duration {
+	interval := w.Interval
+	if interval <= 0 {
+		interval = defaultWatchInterval
+	}
+	return interval + time.Duration(rand.Int63n(int64(interval)/2+1))
+}
+
+// Refresh polls Client.All and Client.AllStatus once, diffs the result
+// against the cache, and emits a TaskEvent for every task added, removed,
+// or changed since the last poll. Concurrent calls to Refresh collapse
+// into a single in-flight poll: a caller that arrives while one is already
+// running doesn't trigger a second round trip to Kapacitor, it waits for
+// the in-flight poll to finish and returns its result.
+func (w *StatusWatcher) Refresh(ctx context.Context) error {
+	w.mu.Lock()
+	if w.inFlight != nil {
+		inFlight := w.inFlight
+		w.mu.Unlock()
+		<-inFlight.done
+		return inFlight.err
+	}
+	inFlight := &refreshResult{done: make(chan struct{})}
+	w.inFlight = inFlight
+	w.mu.Unlock()
+
+	err := w.doRefresh(ctx)
+
+	w.mu.Lock()
+	w.inFlight = nil
+	w.mu.Unlock()
+
+	inFlight.err = err
+	close(inFlight.done)
+	return err
+}
+
+// doRefresh performs the actual poll-and-diff Refresh collapses concurrent
+// callers onto.
+func (w *StatusWatcher) doRefresh(ctx context.Context) error {
+	rules, _, err := w.Client.All(ctx)
+	if err != nil {
+		return err
+	}
+	statuses, err := w.Client.AllStatus(ctx)
+	if err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	now := time.Now()
+	seen := make(map[string]bool, len(rules))
+	for id, rule := range rules {
+		seen[id] = true
+		status := statuses[id]
+		prev, existed := w.cache[id]
+		w.cache[id] = watcherCacheEntry{rule: rule, status: status, expires: now.Add(w.MaxAge)}
+
+		switch {
+		case !existed:
+			w.emit(TaskEvent{Type: TaskAdded, ID: id, Rule: rule, Status: status})
+		default:
+			if prev.rule.TICKScript != rule.TICKScript {
+				w.emit(TaskEvent{Type: TaskScriptChanged, ID: id, Rule: rule, Status: status})
+			}
+			if prev.status != status {
+				w.emit(TaskEvent{Type: TaskStatusChanged, ID: id, Rule: rule, Status: status})
+			}
+		}
+	}
+
+	for id, prev := range w.cache {
+		if seen[id] {
+			continue
+		}
+		delete(w.cache, id)
+		w.emit(TaskEvent{Type: TaskRemoved, ID: id, Rule: prev.rule, Status: prev.status})
+	}
+
+	return nil
+}
+
+// emit fans event out to every subscriber, dropping it for any subscriber
+// whose channel is full. Callers must hold w.mu.
+func (w *StatusWatcher) emit(event TaskEvent) {
+	for _, ch := range w.subs {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+// CachedStatus returns id's status from the cache if it's younger than
+// MaxAge. The second return value is false if there's no fresh entry,
+// meaning the caller should fall back to Client.Status.
+func (w *StatusWatcher) CachedStatus(id string) (string, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	entry, ok := w.cache[id]
+	if !ok || time.Now().After(entry.expires) {
+		return "", false
+	}
+	return entry.status, true
+}
+
+// CachedRule returns id's rule from the cache if it's younger than MaxAge.
+func (w *StatusWatcher) CachedRule(id string) (chronograf.AlertRule, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	entry, ok := w.cache[id]
+	if !ok || time.Now().After(entry.expires) {
+		return chronograf.AlertRule{}, false
+	}
+	return entry.rule, true
+}
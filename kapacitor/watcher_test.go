@@ -0,0 +1,211 @@
+package kapacitor
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	client "github.com/influxdata/kapacitor/client/v1"
+)
+
+func drain(t *testing.T, ch <-chan TaskEvent) []TaskEvent {
+	t.Helper()
+	var events []TaskEvent
+	for {
+		select {
+		case e := <-ch:
+			events = append(events, e)
+		case <-time.After(10 * time.Millisecond):
+			return events
+		}
+	}
+}
+
+func hasEventType(events []TaskEvent, typ TaskEventType) bool {
+	for _, e := range events {
+		if e.Type == typ {
+			return true
+		}
+	}
+	return false
+}
+
+func TestStatusWatcherRefreshEmitsAddedThenChanges(t *testing.T) {
+	kapa := newFakeKapaClient()
+	c := newTestClient(kapa)
+	w := NewStatusWatcher(c, time.Minute, time.Minute)
+
+	events, _ := w.Subscribe()
+
+	if _, err := kapa.CreateTask(client.CreateTaskOptions{ID: "task-1", TICKscript: "-- v1 --", Status: client.Enabled}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := w.Refresh(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	added := drain(t, events)
+	if !hasEventType(added, TaskAdded) {
+		t.Fatalf("expected a TaskAdded event on first sighting, got %+v", added)
+	}
+
+	// Change the script and disable the task between polls.
+	if _, err := kapa.UpdateTask(client.Link{Href: "/kapacitor/v1/tasks/task-1"}, client.UpdateTaskOptions{TICKscript: "-- v2 --", Status: client.Disabled}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := w.Refresh(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	changed := drain(t, events)
+	if !hasEventType(changed, TaskScriptChanged) {
+		t.Fatalf("expected a TaskScriptChanged event, got %+v", changed)
+	}
+	if !hasEventType(changed, TaskStatusChanged) {
+		t.Fatalf("expected a TaskStatusChanged event, got %+v", changed)
+	}
+
+	if err := kapa.DeleteTask(client.Link{Href: "/kapacitor/v1/tasks/task-1"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := w.Refresh(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	removed := drain(t, events)
+	if !hasEventType(removed, TaskRemoved) {
+		t.Fatalf("expected a TaskRemoved event once the task disappears, got %+v", removed)
+	}
+}
+
+// TestStatusWatcherUnsubscribeStopsDelivery pins the fix for a leak: Subscribe
+// used to have no way to stop receiving events, so every caller (e.g. every
+// connected UI client) held its channel open for the watcher's lifetime.
+func TestStatusWatcherUnsubscribeStopsDelivery(t *testing.T) {
+	kapa := newFakeKapaClient()
+	c := newTestClient(kapa)
+	w := NewStatusWatcher(c, time.Minute, time.Minute)
+
+	events, unsubscribe := w.Subscribe()
+	unsubscribe()
+
+	if _, err := kapa.CreateTask(client.CreateTaskOptions{ID: "task-1", TICKscript: "-- v1 --", Status: client.Enabled}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := w.Refresh(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if events := drain(t, events); len(events) != 0 {
+		t.Fatalf("expected no events after unsubscribing, got %+v", events)
+	}
+
+	w.mu.Lock()
+	remaining := len(w.subs)
+	w.mu.Unlock()
+	if remaining != 0 {
+		t.Fatalf("expected unsubscribe to remove the subscriber's channel, %d remain", remaining)
+	}
+}
+
+func TestStatusWatcherCachedStatusRespectsMaxAge(t *testing.T) {
+	kapa := newFakeKapaClient()
+	c := newTestClient(kapa)
+	w := NewStatusWatcher(c, time.Minute, time.Millisecond)
+
+	if _, err := kapa.CreateTask(client.CreateTaskOptions{ID: "task-1", TICKscript: "-- v1 --", Status: client.Enabled}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := w.Refresh(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if status, ok := w.CachedStatus("task-1"); !ok || status != "enabled" {
+		t.Fatalf("expected a fresh cache hit of enabled, got %q, %v", status, ok)
+	}
+
+	time.Sleep(5 * time.Millisecond)
+
+	if _, ok := w.CachedStatus("task-1"); ok {
+		t.Fatalf("expected the cache entry to be stale past MaxAge")
+	}
+}
+
+// blockingListTasksKapaClient counts ListTasks calls and blocks each one on
+// unblock, so a test can hold a poll in flight while other Refresh calls
+// arrive concurrently.
+type blockingListTasksKapaClient struct {
+	fakeKapaClient
+	mu      sync.Mutex
+	calls   int
+	unblock chan struct{}
+	entered chan struct{}
+}
+
+func (f *blockingListTasksKapaClient) ListTasks(opt *client.ListTasksOptions) ([]client.Task, error) {
+	f.mu.Lock()
+	f.calls++
+	f.mu.Unlock()
+	f.entered <- struct{}{}
+	<-f.unblock
+	return f.fakeKapaClient.ListTasks(opt)
+}
+
+// TestStatusWatcherRefreshCollapsesConcurrentCalls pins Refresh's doc-comment
+// promise that concurrent calls collapse into a single in-flight poll: every
+// caller that arrives while a poll is running must wait for it and receive
+// its actual result, not just get an immediate no-op nil.
+func TestStatusWatcherRefreshCollapsesConcurrentCalls(t *testing.T) {
+	kapa := &blockingListTasksKapaClient{
+		fakeKapaClient: *newFakeKapaClient(),
+		unblock:        make(chan struct{}),
+		entered:        make(chan struct{}, 1),
+	}
+	c := newTestClient(kapa)
+	w := NewStatusWatcher(c, time.Minute, time.Minute)
+
+	if _, err := kapa.CreateTask(client.CreateTaskOptions{ID: "task-1", TICKscript: "-- v1 --", Status: client.Enabled}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	const concurrent = 5
+	var wg, started sync.WaitGroup
+	errs := make([]error, concurrent)
+	for i := 0; i < concurrent; i++ {
+		wg.Add(1)
+		started.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			started.Done()
+			errs[i] = w.Refresh(context.Background())
+		}(i)
+	}
+
+	// Wait for every goroutine to have called Refresh, and for the one that
+	// won the race to be blocked inside the poll, before letting it
+	// complete — otherwise a goroutine that's merely slow to schedule could
+	// arrive after the poll finishes and start a second one instead of
+	// collapsing onto it.
+	started.Wait()
+	<-kapa.entered
+	time.Sleep(20 * time.Millisecond)
+	close(kapa.unblock)
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			t.Fatalf("call %d: unexpected error: %v", i, err)
+		}
+	}
+
+	// doRefresh's single poll calls ListTasks twice (once via Client.All,
+	// once via Client.AllStatus); any more than that means a second poll
+	// ran instead of collapsing onto the first.
+	kapa.mu.Lock()
+	calls := kapa.calls
+	kapa.mu.Unlock()
+	if calls != 2 {
+		t.Fatalf("expected concurrent Refresh calls to collapse into a single poll (2 ListTasks calls), got %d", calls)
+	}
+}
@@ -0,0 +1,228 @@
+package kapacitor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/influxdata/chronograf"
+	"github.com/influxdata/chronograf/uuid"
+)
+
+// WebhookPayload is POSTed to a WebhookBackend's endpoint whenever a task is
+// created, updated, enabled, disabled, or deleted. It carries enough of the rule for
+// a generic receiver to re-derive what fired without understanding
+// TICKscript or Kapacitor's task model.
+type WebhookPayload struct {
+	Event string               `json:"event"`
+	Rule  chronograf.AlertRule `json:"rule"`
+}
+
+// WebhookBackend dispatches AlertRules to a single generic HTTP endpoint
+// instead of running them as scheduled tasks. It lets a rule's "execution"
+// be delegated entirely to whatever owns that endpoint; Chronograf just
+// keeps the bookkeeping of which rules exist and whether they're enabled.
+type WebhookBackend struct {
+	// URL is the endpoint every WebhookPayload is POSTed to.
+	URL string
+	// Client performs the HTTP requests; defaults to http.DefaultClient.
+	Client *http.Client
+	// ID generates task IDs for newly created rules.
+	ID chronograf.ID
+
+	mu      sync.Mutex
+	tasks   map[string]*Task
+	enabled map[string]bool
+}
+
+// NewWebhookBackend creates an AlertBackend that POSTs rule events to url.
+func NewWebhookBackend(url string) *WebhookBackend {
+	return &WebhookBackend{
+		URL:     url,
+		Client:  http.DefaultClient,
+		ID:      &uuid.V4{},
+		tasks:   map[string]*Task{},
+		enabled: map[string]bool{},
+	}
+}
+
+func (w *WebhookBackend) notify(ctx context.Context, event string, rule chronograf.AlertRule) error {
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(WebhookPayload{Event: event, Rule: rule}); err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", w.URL, &buf)
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := w.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("webhook: %s notification rejected: %s", event, resp.Status)
+	}
+	return nil
+}
+
+// webhookRevision hashes the full rule, not just its ID and name, so a
+// concurrent edit to any field Update can change is reflected in the
+// revision Update's CAS check compares against.
+func webhookRevision(rule chronograf.AlertRule) string {
+	b, err := json.Marshal(rule)
+	if err != nil {
+		return hashRevision(rule.ID, rule.Name)
+	}
+	return hashRevision(string(b))
+}
+
+// Create registers rule and notifies the webhook endpoint it now exists.
+func (w *WebhookBackend) Create(ctx context.Context, rule chronograf.AlertRule) (*Task, error) {
+	id, err := w.ID.Generate()
+	if err != nil {
+		return nil, err
+	}
+	kapaID := Prefix + id
+	rule.ID = kapaID
+
+	if err := w.notify(ctx, "create", rule); err != nil {
+		return nil, err
+	}
+
+	task := &Task{
+		ID:       kapaID,
+		Href:     fmt.Sprintf("/webhook/v1/rules/%s", kapaID),
+		Rule:     rule,
+		Revision: webhookRevision(rule),
+	}
+
+	w.mu.Lock()
+	w.tasks[task.Href] = task
+	w.enabled[task.Href] = true
+	w.mu.Unlock()
+
+	return task, nil
+}
+
+// Update replaces the rule at href and notifies the webhook endpoint. If
+// revision is non-empty, it must match the task's current revision or
+// Update returns ErrConflict without notifying the endpoint.
+func (w *WebhookBackend) Update(ctx context.Context, href, revision string, rule chronograf.AlertRule) (*Task, error) {
+	w.mu.Lock()
+	existing, ok := w.tasks[href]
+	w.mu.Unlock()
+	if !ok {
+		return nil, chronograf.ErrAlertNotFound
+	}
+	if revision != "" && existing.Revision != revision {
+		return nil, ErrConflict{ID: existing.ID}
+	}
+
+	rule.ID = existing.ID
+	if err := w.notify(ctx, "update", rule); err != nil {
+		return nil, err
+	}
+
+	task := &Task{ID: existing.ID, Href: href, Rule: rule, Revision: webhookRevision(rule)}
+	w.mu.Lock()
+	w.tasks[href] = task
+	w.mu.Unlock()
+
+	return task, nil
+}
+
+// Delete removes the rule at href and notifies the webhook endpoint.
+func (w *WebhookBackend) Delete(ctx context.Context, href string) error {
+	w.mu.Lock()
+	task, ok := w.tasks[href]
+	w.mu.Unlock()
+	if !ok {
+		return chronograf.ErrAlertNotFound
+	}
+
+	if err := w.notify(ctx, "delete", task.Rule); err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	delete(w.tasks, href)
+	delete(w.enabled, href)
+	w.mu.Unlock()
+	return nil
+}
+
+func (w *WebhookBackend) setEnabled(ctx context.Context, href string, enabled bool, event string) (*Task, error) {
+	w.mu.Lock()
+	task, ok := w.tasks[href]
+	w.mu.Unlock()
+	if !ok {
+		return nil, chronograf.ErrAlertNotFound
+	}
+
+	if err := w.notify(ctx, event, task.Rule); err != nil {
+		return nil, err
+	}
+
+	w.mu.Lock()
+	w.enabled[href] = enabled
+	w.mu.Unlock()
+	return task, nil
+}
+
+// Enable marks the rule at href enabled and notifies the webhook endpoint.
+func (w *WebhookBackend) Enable(ctx context.Context, href string) (*Task, error) {
+	return w.setEnabled(ctx, href, true, "enable")
+}
+
+// Disable marks the rule at href disabled and notifies the webhook endpoint.
+func (w *WebhookBackend) Disable(ctx context.Context, href string) (*Task, error) {
+	return w.setEnabled(ctx, href, false, "disable")
+}
+
+// Get returns a single rule and its revision known to the backend.
+func (w *WebhookBackend) Get(ctx context.Context, id string) (chronograf.AlertRule, string, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, task := range w.tasks {
+		if task.ID == id {
+			return task.Rule, task.Revision, nil
+		}
+	}
+	return chronograf.AlertRule{}, "", chronograf.ErrAlertNotFound
+}
+
+// All returns every rule and its revision known to the backend, keyed by
+// task ID.
+func (w *WebhookBackend) All(ctx context.Context) (map[string]chronograf.AlertRule, map[string]string, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	alerts := map[string]chronograf.AlertRule{}
+	revisions := map[string]string{}
+	for _, task := range w.tasks {
+		alerts[task.ID] = task.Rule
+		revisions[task.ID] = task.Revision
+	}
+	return alerts, revisions, nil
+}
+
+// Status returns "enabled" or "disabled" for the rule at href.
+func (w *WebhookBackend) Status(ctx context.Context, href string) (string, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if _, ok := w.tasks[href]; !ok {
+		return "", chronograf.ErrAlertNotFound
+	}
+	if w.enabled[href] {
+		return "enabled", nil
+	}
+	return "disabled", nil
+}
+
+var _ AlertBackend = &WebhookBackend{}
@@ -0,0 +1,64 @@
+package kapacitor
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/influxdata/chronograf"
+)
+
+func TestWebhookBackendCreateNotifiesEndpoint(t *testing.T) {
+	var gotEvent string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload WebhookPayload
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Fatalf("bad payload: %v", err)
+		}
+		gotEvent = payload.Event
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	w := NewWebhookBackend(srv.URL)
+	task, err := w.Create(context.Background(), chronograf.AlertRule{Name: "cpu high"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if gotEvent != "create" {
+		t.Fatalf("expected create event, got %q", gotEvent)
+	}
+
+	status, err := w.Status(context.Background(), task.Href)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if status != "enabled" {
+		t.Fatalf("expected newly created task to be enabled, got %q", status)
+	}
+}
+
+// TestWebhookBackendDeleteRespectsContext pins the fix for a bug where
+// Delete ignored its ctx argument and used context.Background() for the
+// notify call, so a caller's cancellation/timeout never reached the
+// endpoint request.
+func TestWebhookBackendDeleteRespectsContext(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	w := NewWebhookBackend(srv.URL)
+	const href = "/webhook/v1/rules/test"
+	w.tasks[href] = &Task{ID: "test", Href: href, Rule: chronograf.AlertRule{Name: "cpu high"}}
+	w.enabled[href] = true
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := w.Delete(ctx, href); err == nil {
+		t.Fatalf("expected Delete to fail fast on an already-canceled context")
+	}
+}